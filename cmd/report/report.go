@@ -0,0 +1,98 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metal-toolbox/component-inventory/cmd"
+	"github.com/metal-toolbox/component-inventory/pkg/api/client"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverAddress string
+	authToken     string
+	facility      string
+	serverIDs     []string
+	inband        bool
+	asJSON        bool
+	timeout       time.Duration
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "generate reports from a component inventory service",
+}
+
+var degradedCmd = &cobra.Command{
+	Use:   "degraded",
+	Short: "list every component in a non-OK state across a facility or a set of servers",
+	Run: func(c *cobra.Command, args []string) {
+		if facility == "" && len(serverIDs) == 0 {
+			log.Fatal("one of --facility or --server is required")
+		}
+
+		cisClient, err := client.NewClient(serverAddress, client.WithAuthToken(authToken))
+		if err != nil {
+			log.Fatalf("creating client: %s", err.Error())
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), timeout)
+		defer cancel()
+
+		report, err := cisClient.GetDegradedReport(ctx, &client.ReportParams{
+			Facility:  facility,
+			ServerIDs: serverIDs,
+			Inband:    inband,
+		})
+		if err != nil {
+			log.Fatalf("fetching report: %s", err.Error())
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			//nolint:errcheck
+			enc.Encode(report)
+			return
+		}
+
+		printReport(report)
+	},
+}
+
+func printReport(report *health.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tSLUG\tSLOT\tSERIAL\tSTATUS\tREASONS")
+	for _, d := range report.Degraded {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.ServerID, d.Slug, d.Slot, d.Serial, d.Status, strings.Join(d.Reasons, "; "))
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d servers scanned, %d degraded components\n", report.ServersScanned, len(report.Degraded))
+	for id, reason := range report.Errors {
+		fmt.Printf("unable to evaluate %s: %s\n", id, reason)
+	}
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(degradedCmd)
+
+	reportCmd.PersistentFlags().StringVar(&serverAddress, "server-address", "http://localhost:8020", "component inventory service address")
+	reportCmd.PersistentFlags().StringVar(&authToken, "auth-token", os.Getenv("CIS_AUTH_TOKEN"), "bearer token for the component inventory service")
+	reportCmd.PersistentFlags().DurationVar(&timeout, "timeout", 5*time.Minute, "time allowed for the report to complete; the service limits the scan of the servers to 4 minutes")
+
+	degradedCmd.Flags().StringVar(&facility, "facility", "", "report on every server in this facility")
+	degradedCmd.Flags().StringSliceVar(&serverIDs, "server", nil, "report on these server ids")
+	degradedCmd.Flags().BoolVar(&inband, "inband", false, "use inband inventory instead of out-of-band")
+	degradedCmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON")
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
@@ -12,6 +13,8 @@ import (
 	"github.com/equinix-labs/otel-init-go/otelinit"
 	"github.com/hashicorp/go-retryablehttp"
 	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
@@ -19,7 +22,11 @@ import (
 
 	rootCmd "github.com/metal-toolbox/component-inventory/cmd"
 	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/internal/inventorycache"
+	"github.com/metal-toolbox/component-inventory/internal/merge"
 	"github.com/metal-toolbox/component-inventory/internal/metrics"
+	"github.com/metal-toolbox/component-inventory/internal/readiness"
+	"github.com/metal-toolbox/component-inventory/internal/serverlock"
 	"github.com/metal-toolbox/component-inventory/internal/version"
 	"github.com/metal-toolbox/component-inventory/pkg/api/routes"
 	"github.com/spf13/cobra"
@@ -28,11 +35,26 @@ import (
 const (
 	dialTimeout     = 30 * time.Second
 	shutdownTimeout = 10 * time.Second
+
+	// readiness results are reused for this long so probes don't load our dependencies
+	readinessCacheTTL = 10 * time.Second
+	readinessTimeout  = 5 * time.Second
+	// the FleetDB endpoint used to check reachability
+	fleetDBReadinessPath = "/_health/readiness"
+
+	// how often a replica checks whether a server lock held elsewhere was released
+	serverLockRetryInterval = 100 * time.Millisecond
 )
 
-func getFleetDBClient(cfg *app.Configuration) (*fleetdb.Client, error) {
+var errNotReady = errors.New("dependency not ready")
+
+// getFleetDBClient returns a FleetDB client and, when OAuth is enabled, the
+// configuration it gets its tokens with.
+func getFleetDBClient(cfg *app.Configuration) (*fleetdb.Client, *clientcredentials.Config, error) {
 	if cfg.FleetDBOpts.DisableOAuth {
-		return fleetdb.NewClient(cfg.FleetDBOpts.Endpoint, nil)
+		// the otel http client propagates trace context to FleetDB
+		client, err := fleetdb.NewClient(cfg.FleetDBOpts.Endpoint, otelhttp.DefaultClient)
+		return client, nil, err
 	}
 
 	ctx := context.Background()
@@ -40,13 +62,10 @@ func getFleetDBClient(cfg *app.Configuration) (*fleetdb.Client, error) {
 	// init retryable http client
 	retryableClient := retryablehttp.NewClient()
 
-	// set retryable HTTP client to be the otel http client to collect telemetry
-	retryableClient.HTTPClient = otelhttp.DefaultClient
-
 	// setup oidc provider
 	provider, err := oidc.NewProvider(ctx, cfg.FleetDBOpts.IssuerEndpoint)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	clientID := "component-inventory"
@@ -66,20 +85,118 @@ func getFleetDBClient(cfg *app.Configuration) (*fleetdb.Client, error) {
 		AuthStyle: oauth2.AuthStyleInParams,
 	}
 
-	// wrap OAuth transport, cookie jar in the retryable client
+	// wrap OAuth transport, cookie jar in the retryable client. The OAuth transport
+	// is wrapped in turn by an otel transport to collect telemetry and propagate
+	// trace context to FleetDB.
 	oAuthclient := oauthConfig.Client(ctx)
 
-	retryableClient.HTTPClient.Transport = oAuthclient.Transport
-	retryableClient.HTTPClient.Jar = oAuthclient.Jar
+	retryableClient.HTTPClient = &http.Client{
+		Transport: otelhttp.NewTransport(oAuthclient.Transport),
+		Jar:       oAuthclient.Jar,
+	}
 
 	httpClient := retryableClient.StandardClient()
 	httpClient.Timeout = dialTimeout
 
-	return fleetdb.NewClientWithToken(
+	client, err := fleetdb.NewClientWithToken(
 		cfg.FleetDBOpts.ClientSecret,
 		cfg.FleetDBOpts.Endpoint,
 		httpClient,
 	)
+	return client, &oauthConfig, err
+}
+
+// getReadinessChecker composes the checks for the dependencies we need to serve requests.
+func getReadinessChecker(cfg *app.Configuration, oauthConfig *clientcredentials.Config) *readiness.Checker {
+	checker := readiness.NewChecker(readinessCacheTTL, readinessTimeout)
+
+	checker.Add("fleetdb", func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.FleetDBOpts.Endpoint+fleetDBReadinessPath, http.NoBody)
+		if err != nil {
+			return err
+		}
+
+		resp, err := otelhttp.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%w: status code %d", errNotReady, resp.StatusCode)
+		}
+		return nil
+	})
+
+	if oauthConfig != nil {
+		// a new token rather than the cached one of the client, requested
+		// within the time the check is given
+		checker.Add("oidc", func(ctx context.Context) error {
+			_, err := oauthConfig.Token(ctx)
+			return err
+		})
+	}
+
+	return checker
+}
+
+// getServerLocker returns the Locker used to serialize updates to a server and a
+// function releasing its resources. A nil Locker selects the in-process default.
+func getServerLocker(ctx context.Context, cfg *app.Configuration) (serverlock.Locker, func(), error) {
+	opts := cfg.ServerLockOpts
+	if opts.Backend != app.ServerLockNATS {
+		return nil, func() {}, nil
+	}
+
+	natsOpts := []nats.Option{nats.Name("component-inventory")}
+	if opts.NATSCredsFile != "" {
+		natsOpts = append(natsOpts, nats.UserCredentials(opts.NATSCredsFile))
+	}
+
+	nc, err := nats.Connect(opts.NATSURL, natsOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
+
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket: opts.Bucket,
+		TTL:    opts.TTL,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
+
+	return serverlock.NewNATS(kv, serverLockRetryInterval), nc.Close, nil
+}
+
+// getInventoryCache returns the configured cache of server inventories, or nil
+// when caching is disabled.
+func getInventoryCache(cfg *app.Configuration) *inventorycache.Cache {
+	if cfg.InventoryCacheOpts.Disabled {
+		return nil
+	}
+	return inventorycache.NewCache(cfg.InventoryCacheOpts.Size, cfg.InventoryCacheOpts.TTL)
+}
+
+// getMergePolicies returns the configured policies for merging submitted
+// inventories with stored ones.
+func getMergePolicies(cfg *app.Configuration) (*merge.Policies, error) {
+	rules := make([]merge.Rule, 0, len(cfg.MergePolicies))
+	for _, mp := range cfg.MergePolicies {
+		rules = append(rules, merge.Rule{
+			Slug:   mp.Slug,
+			Field:  mp.Field,
+			Policy: merge.Policy(mp.Policy),
+		})
+	}
+	return merge.New(rules)
 }
 
 // install server command
@@ -96,17 +213,44 @@ var serverCmd = &cobra.Command{
 		//nolint:errcheck
 		defer logger.Sync()
 
-		fdb, err := getFleetDBClient(cfg)
+		fdb, oauthConfig, err := getFleetDBClient(cfg)
 		if err != nil {
 			logger.With(
 				zap.Error(err),
 			).Fatal("creating fleetdb client")
 		}
 
+		locker, closeLocker, err := getServerLocker(c.Context(), cfg)
+		if err != nil {
+			logger.With(
+				zap.Error(err),
+			).Fatal("creating server locker")
+		}
+		defer closeLocker()
+
+		policies, err := getMergePolicies(cfg)
+		if err != nil {
+			logger.With(
+				zap.Error(err),
+			).Fatal("loading merge policies")
+		}
+
 		ctx, appCancel := context.WithCancel(c.Context())
-		app := app.NewApp(ctx, cfg, logger, fdb)
+		app := app.NewApp(ctx, cfg, logger, fdb,
+			app.WithReadiness(getReadinessChecker(cfg, oauthConfig)),
+			app.WithServerLocker(locker),
+			app.WithInventoryCache(getInventoryCache(cfg)),
+			app.WithMergePolicies(policies),
+		)
 
-		metrics.ListenAndServe()
+		metricsSrv := metrics.NewServer(cfg.MetricsOpts.ListenAddress)
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("error serving metrics",
+					zap.Error(err),
+				)
+			}
+		}()
 
 		// the ignored parameter here is a context annotated with otel-init-go configuration
 		_, otelShutdown := otelinit.InitOpenTelemetry(c.Context(), "cis-api-server")
@@ -117,7 +261,7 @@ var serverCmd = &cobra.Command{
 
 		srv := routes.ComposeHTTPServer(app)
 		go func() {
-			if err := srv.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				logger.Fatal("error serving API",
 					zap.Error(err),
 				)
@@ -136,6 +280,11 @@ var serverCmd = &cobra.Command{
 				zap.Error(err),
 			)
 		}
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			logger.Error("metrics server shutdown error",
+				zap.Error(err),
+			)
+		}
 		otelShutdown(ctx)
 		logger.Info("OK, done.")
 	},
@@ -6,7 +6,12 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/metal-toolbox/component-inventory/internal/inventorycache"
+	"github.com/metal-toolbox/component-inventory/internal/merge"
+	"github.com/metal-toolbox/component-inventory/internal/readiness"
+	"github.com/metal-toolbox/component-inventory/internal/serverlock"
 	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
 
 	"github.com/pkg/errors"
@@ -15,16 +20,72 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// DefaultMetricsListenAddress is used when no metrics listen address is configured
+const DefaultMetricsListenAddress = "0.0.0.0:9090"
+
+const (
+	// DefaultIdempotencyWindow is used when no idempotency window is configured
+	DefaultIdempotencyWindow = time.Hour
+	// DefaultIdempotencyMaxEntries is used when no maximum number of
+	// idempotency keys is configured
+	DefaultIdempotencyMaxEntries = 100000
+)
+
+const (
+	// ServerLockLocal serializes updates to a server within a single replica
+	ServerLockLocal = "local"
+	// ServerLockNATS serializes updates to a server across replicas using NATS
+	ServerLockNATS = "nats"
+
+	// DefaultServerLockBucket is used when no lock bucket is configured
+	DefaultServerLockBucket = "component-inventory-locks"
+	// DefaultServerLockTTL is used when no lock TTL is configured
+	DefaultServerLockTTL = time.Minute
+)
+
+const (
+	// DefaultInventoryCacheSize is used when no inventory cache size is configured
+	DefaultInventoryCacheSize = 1024
+	// DefaultInventoryCacheTTL is used when no inventory cache TTL is configured
+	DefaultInventoryCacheTTL = 30 * time.Second
+)
+
+// DefaultMinCompressedResponseBytes is used when no minimum size for
+// compressing responses is configured
+const DefaultMinCompressedResponseBytes = 1024
+
+// Request body limits used when none are configured
+var DefaultBodyLimits = BodyLimitOptions{
+	Inventory:       32 << 20,
+	Overrides:       1 << 20,
+	Default:         1 << 20,
+	MaxJSONDepth:    32,
+	MaxJSONElements: 10000,
+}
+
+// Rate limits used when none are configured
+var (
+	DefaultReadRateLimit  = RateLimit{RequestsPerSecond: 50, Burst: 200}
+	DefaultWriteRateLimit = RateLimit{RequestsPerSecond: 10, Burst: 50}
+)
+
 // XXX: be careful here. Compound names need to be valid prometheus metric names (used in internal/metrics.go)
 const AppName = "component_inventory"
 
 type App struct {
-	Log     *zap.Logger
-	Cfg     *Configuration
-	FleetDB *fleetdb.Client
-	ctx     context.Context
-	term    <-chan os.Signal
-	opts    map[string]any
+	Log       *zap.Logger
+	Cfg       *Configuration
+	FleetDB   *fleetdb.Client
+	Readiness *readiness.Checker
+	// ServerLock serializes inventory updates to the same server
+	ServerLock serverlock.Locker
+	// InventoryCache serves repeated reads of server inventories, nil when disabled
+	InventoryCache *inventorycache.Cache
+	// MergePolicies keep existing component data submissions lack, nil to replace it
+	MergePolicies *merge.Policies
+	ctx           context.Context
+	term          <-chan os.Signal
+	opts          map[string]any
 }
 
 // Option provides a path for adding arbitrary stuff to an App.
@@ -37,6 +98,34 @@ func NewOption(key string, opt any) Option {
 	}
 }
 
+// WithReadiness sets the checker used to report whether the App's dependencies are usable.
+func WithReadiness(r *readiness.Checker) Option {
+	return func(a *App) {
+		a.Readiness = r
+	}
+}
+
+// WithServerLocker sets the Locker used to serialize updates to a server.
+func WithServerLocker(l serverlock.Locker) Option {
+	return func(a *App) {
+		a.ServerLock = l
+	}
+}
+
+// WithInventoryCache sets the cache of server inventories read from FleetDB.
+func WithInventoryCache(c *inventorycache.Cache) Option {
+	return func(a *App) {
+		a.InventoryCache = c
+	}
+}
+
+// WithMergePolicies sets the policies merging submitted inventories with stored ones.
+func WithMergePolicies(p *merge.Policies) Option {
+	return func(a *App) {
+		a.MergePolicies = p
+	}
+}
+
 // NewApp composes the provided Configuration and Logger into a new App object
 func NewApp(ctx context.Context, cfg *Configuration, log *zap.Logger, fdb *fleetdb.Client, opts ...Option) *App {
 	termChan := make(chan os.Signal, 1)
@@ -47,12 +136,17 @@ func NewApp(ctx context.Context, cfg *Configuration, log *zap.Logger, fdb *fleet
 		FleetDB: fdb,
 		ctx:     ctx,
 		term:    termChan,
+		opts:    map[string]any{},
 	}
 
 	for _, opt := range opts {
 		opt(app)
 	}
 
+	if app.ServerLock == nil {
+		app.ServerLock = serverlock.NewLocal()
+	}
+
 	return app
 }
 
@@ -74,6 +168,26 @@ func (a *App) LogRunningConfig() {
 		zap.String("fleetdb.address", a.Cfg.FleetDBOpts.Endpoint),
 		zap.String("listen.address", a.Cfg.ListenAddress),
 		zap.Bool("developer.mode", a.Cfg.DeveloperMode),
+		zap.String("metrics.listen.address", a.Cfg.MetricsOpts.ListenAddress),
+		zap.Bool("metrics.serve.on.api", a.Cfg.MetricsOpts.ServeOnAPI),
+		zap.Duration("idempotency.window", a.Cfg.IdempotencyWindow),
+		zap.Int("idempotency.max.entries", a.Cfg.IdempotencyMaxEntries),
+		zap.String("server.lock.backend", a.Cfg.ServerLockOpts.Backend),
+		zap.Bool("inventory.cache.disabled", a.Cfg.InventoryCacheOpts.Disabled),
+		zap.Int("inventory.cache.size", a.Cfg.InventoryCacheOpts.Size),
+		zap.Duration("inventory.cache.ttl", a.Cfg.InventoryCacheOpts.TTL),
+		zap.Bool("rate.limit.disabled", a.Cfg.RateLimitOpts.Disabled),
+		zap.Float64("rate.limit.read.requests.per.second", a.Cfg.RateLimitOpts.Read.RequestsPerSecond),
+		zap.Int("rate.limit.read.burst", a.Cfg.RateLimitOpts.Read.Burst),
+		zap.Float64("rate.limit.write.requests.per.second", a.Cfg.RateLimitOpts.Write.RequestsPerSecond),
+		zap.Int("rate.limit.write.burst", a.Cfg.RateLimitOpts.Write.Burst),
+		zap.Int("compression.min.response.bytes", a.Cfg.CompressionOpts.MinResponseBytes),
+		zap.Int64("body.limits.inventory", a.Cfg.BodyLimitOpts.Inventory),
+		zap.Int64("body.limits.overrides", a.Cfg.BodyLimitOpts.Overrides),
+		zap.Int64("body.limits.default", a.Cfg.BodyLimitOpts.Default),
+		zap.Int("body.limits.max.json.depth", a.Cfg.BodyLimitOpts.MaxJSONDepth),
+		zap.Int("body.limits.max.json.elements", a.Cfg.BodyLimitOpts.MaxJSONElements),
+		zap.Int("merge.policies", len(a.Cfg.MergePolicies)),
 		// do something for the JWTAuthConfig
 	)
 }
@@ -128,6 +242,75 @@ func envVarOverrides(v *viper.Viper, cfg *Configuration) error {
 		cfg.DeveloperMode = true
 	}
 
+	if addr := v.GetString("metrics.listen.address"); addr != "" {
+		cfg.MetricsOpts.ListenAddress = addr
+	}
+
+	if cfg.MetricsOpts.ListenAddress == "" {
+		cfg.MetricsOpts.ListenAddress = DefaultMetricsListenAddress
+	}
+
+	if v.GetBool("metrics.serve.on.api") {
+		cfg.MetricsOpts.ServeOnAPI = true
+	}
+
+	if window := v.GetDuration("idempotency.window"); window != 0 {
+		cfg.IdempotencyWindow = window
+	}
+
+	if cfg.IdempotencyWindow <= 0 {
+		cfg.IdempotencyWindow = DefaultIdempotencyWindow
+	}
+
+	if maxEntries := v.GetInt("idempotency.max.entries"); maxEntries != 0 {
+		cfg.IdempotencyMaxEntries = maxEntries
+	}
+
+	if cfg.IdempotencyMaxEntries <= 0 {
+		cfg.IdempotencyMaxEntries = DefaultIdempotencyMaxEntries
+	}
+
+	if err := serverLockOverrides(v, cfg); err != nil {
+		return err
+	}
+
+	if v.GetBool("inventory.cache.disabled") {
+		cfg.InventoryCacheOpts.Disabled = true
+	}
+
+	if size := v.GetInt("inventory.cache.size"); size != 0 {
+		cfg.InventoryCacheOpts.Size = size
+	}
+
+	if cfg.InventoryCacheOpts.Size <= 0 {
+		cfg.InventoryCacheOpts.Size = DefaultInventoryCacheSize
+	}
+
+	if ttl := v.GetDuration("inventory.cache.ttl"); ttl != 0 {
+		cfg.InventoryCacheOpts.TTL = ttl
+	}
+
+	if cfg.InventoryCacheOpts.TTL <= 0 {
+		cfg.InventoryCacheOpts.TTL = DefaultInventoryCacheTTL
+	}
+
+	if v.GetBool("rate.limit.disabled") {
+		cfg.RateLimitOpts.Disabled = true
+	}
+
+	rateLimitOverrides(v, "rate.limit.read", &cfg.RateLimitOpts.Read, DefaultReadRateLimit)
+	rateLimitOverrides(v, "rate.limit.write", &cfg.RateLimitOpts.Write, DefaultWriteRateLimit)
+
+	if size := v.GetInt("compression.min.response.bytes"); size != 0 {
+		cfg.CompressionOpts.MinResponseBytes = size
+	}
+
+	if cfg.CompressionOpts.MinResponseBytes <= 0 {
+		cfg.CompressionOpts.MinResponseBytes = DefaultMinCompressedResponseBytes
+	}
+
+	bodyLimitOverrides(v, &cfg.BodyLimitOpts)
+
 	// sanity checks
 	if v.GetString("fleetdb.disable.oauth") != "" {
 		cfg.FleetDBOpts.DisableOAuth = v.GetBool("fleetdb.disable.oauth")
@@ -192,3 +375,106 @@ func GetLogger(dev bool) *zap.Logger {
 		zap.AddCaller(),
 	))
 }
+
+func serverLockOverrides(v *viper.Viper, cfg *Configuration) error {
+	opts := &cfg.ServerLockOpts
+
+	if backend := v.GetString("server.lock.backend"); backend != "" {
+		opts.Backend = backend
+	}
+
+	if url := v.GetString("server.lock.nats.url"); url != "" {
+		opts.NATSURL = url
+	}
+
+	if creds := v.GetString("server.lock.nats.creds.file"); creds != "" {
+		opts.NATSCredsFile = creds
+	}
+
+	if bucket := v.GetString("server.lock.bucket"); bucket != "" {
+		opts.Bucket = bucket
+	}
+
+	if ttl := v.GetDuration("server.lock.ttl"); ttl != 0 {
+		opts.TTL = ttl
+	}
+
+	switch opts.Backend {
+	case "":
+		opts.Backend = ServerLockLocal
+	case ServerLockLocal:
+	case ServerLockNATS:
+		if opts.NATSURL == "" {
+			return errors.New("server lock NATS url not defined")
+		}
+	default:
+		return errors.New("unknown server lock backend " + opts.Backend)
+	}
+
+	if opts.Bucket == "" {
+		opts.Bucket = DefaultServerLockBucket
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultServerLockTTL
+	}
+
+	return nil
+}
+
+func rateLimitOverrides(v *viper.Viper, prefix string, limit *RateLimit, defaults RateLimit) {
+	if rps := v.GetFloat64(prefix + ".requests.per.second"); rps != 0 {
+		limit.RequestsPerSecond = rps
+	}
+
+	if limit.RequestsPerSecond <= 0 {
+		limit.RequestsPerSecond = defaults.RequestsPerSecond
+	}
+
+	if burst := v.GetInt(prefix + ".burst"); burst != 0 {
+		limit.Burst = burst
+	}
+
+	if limit.Burst <= 0 {
+		limit.Burst = defaults.Burst
+	}
+}
+
+// bodyLimitOverrides applies the environment overrides of the request body
+// limits, and the defaults of those that aren't set.
+func bodyLimitOverrides(v *viper.Viper, limits *BodyLimitOptions) {
+	sizes := []struct {
+		key     string
+		limit   *int64
+		initial int64
+	}{
+		{"body.limits.inventory", &limits.Inventory, DefaultBodyLimits.Inventory},
+		{"body.limits.overrides", &limits.Overrides, DefaultBodyLimits.Overrides},
+		{"body.limits.default", &limits.Default, DefaultBodyLimits.Default},
+	}
+	for _, size := range sizes {
+		if n := v.GetInt64(size.key); n != 0 {
+			*size.limit = n
+		}
+
+		if *size.limit <= 0 {
+			*size.limit = size.initial
+		}
+	}
+
+	if depth := v.GetInt("body.limits.max.json.depth"); depth != 0 {
+		limits.MaxJSONDepth = depth
+	}
+
+	if limits.MaxJSONDepth <= 0 {
+		limits.MaxJSONDepth = DefaultBodyLimits.MaxJSONDepth
+	}
+
+	if elements := v.GetInt("body.limits.max.json.elements"); elements != 0 {
+		limits.MaxJSONElements = elements
+	}
+
+	if limits.MaxJSONElements <= 0 {
+		limits.MaxJSONElements = DefaultBodyLimits.MaxJSONElements
+	}
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"time"
+
 	"go.hollow.sh/toolbox/ginjwt"
 )
 
@@ -9,6 +11,103 @@ type Configuration struct {
 	DeveloperMode bool                `mapstructure:"developer_mode"`
 	JWTAuth       []ginjwt.AuthConfig `mapstructure:"ginjwt_auth"`
 	FleetDBOpts   FleetDBAPIOptions   `mapstructure:"fleetdb"`
+	MetricsOpts   MetricsOptions      `mapstructure:"metrics"`
+	// IdempotencyWindow is how long responses to requests with an Idempotency-Key are kept
+	IdempotencyWindow time.Duration `mapstructure:"idempotency_window"`
+	// IdempotencyMaxEntries is how many Idempotency-Keys are kept, the least
+	// recently used are dropped first
+	IdempotencyMaxEntries int                   `mapstructure:"idempotency_max_entries"`
+	ServerLockOpts        ServerLockOptions     `mapstructure:"server_lock"`
+	InventoryCacheOpts    InventoryCacheOptions `mapstructure:"inventory_cache"`
+	RateLimitOpts         RateLimitOptions      `mapstructure:"rate_limit"`
+	CompressionOpts       CompressionOptions    `mapstructure:"compression"`
+	BodyLimitOpts         BodyLimitOptions      `mapstructure:"body_limits"`
+	// MergePolicies decide which existing component data is kept when an
+	// inventory is submitted without it; by default submissions replace it
+	MergePolicies []MergePolicy `mapstructure:"merge_policies"`
+}
+
+// MergePolicy sets how a field of the components with a slug is merged, see
+// internal/merge for the policies and field names
+type MergePolicy struct {
+	// Slug is a component slug, or "*" for all of them
+	Slug string `mapstructure:"slug"`
+	// Field is a component field JSON key, e.g. "attributes.smart_status", or "*"
+	Field  string `mapstructure:"field"`
+	Policy string `mapstructure:"policy"`
+}
+
+// CompressionOptions control gzip and zstd compressed responses
+type CompressionOptions struct {
+	// MinResponseBytes is the size from which responses are compressed for
+	// clients that accept it
+	MinResponseBytes int `mapstructure:"min_response_bytes"`
+}
+
+// BodyLimitOptions bound request bodies, compressed ones once decompressed
+type BodyLimitOptions struct {
+	// Inventory is the maximum size in bytes of inventory submissions
+	Inventory int64 `mapstructure:"inventory"`
+	// Overrides is the maximum size in bytes of component overrides
+	Overrides int64 `mapstructure:"overrides"`
+	// Default is the maximum size in bytes of the bodies of other routes
+	Default int64 `mapstructure:"default"`
+	// MaxJSONDepth is how deep arrays and objects of JSON bodies may be nested
+	MaxJSONDepth int `mapstructure:"max_json_depth"`
+	// MaxJSONElements is how many elements an array, or members an object, of
+	// a JSON body may have
+	MaxJSONElements int `mapstructure:"max_json_elements"`
+}
+
+// RateLimitOptions control how many requests each client, identified by its JWT
+// subject or else its IP address, may make
+type RateLimitOptions struct {
+	// Disabled lets clients make any number of requests
+	Disabled bool `mapstructure:"disabled"`
+	// Read limits requests that only read inventory
+	Read RateLimit `mapstructure:"read"`
+	// Write limits inventory submissions
+	Write RateLimit `mapstructure:"write"`
+}
+
+// RateLimit is a token bucket allowing RequestsPerSecond on average and bursts
+// of up to Burst requests
+type RateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// InventoryCacheOptions control the cache of server inventories read from FleetDB
+type InventoryCacheOptions struct {
+	// Disabled makes every read go to FleetDB
+	Disabled bool `mapstructure:"disabled"`
+	// Size is the number of server inventories kept
+	Size int `mapstructure:"size"`
+	// TTL is how long an inventory is served from the cache
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// ServerLockOptions control how concurrent updates to the same server are serialized
+type ServerLockOptions struct {
+	// Backend is either "local" for in-process locks or "nats" for locks shared
+	// between replicas
+	Backend string `mapstructure:"backend"`
+	// NATSURL is the NATS server used by the nats backend
+	NATSURL string `mapstructure:"nats_url"`
+	// NATSCredsFile is an optional NATS credentials file
+	NATSCredsFile string `mapstructure:"nats_creds_file"`
+	// Bucket is the JetStream key-value bucket holding the locks
+	Bucket string `mapstructure:"bucket"`
+	// TTL bounds how long a lock outlives a replica that died holding it
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// MetricsOptions control how prometheus metrics are exposed
+type MetricsOptions struct {
+	// ListenAddress is the address of the dedicated metrics listener
+	ListenAddress string `mapstructure:"listen_address"`
+	// ServeOnAPI additionally exposes /metrics on the API listener
+	ServeOnAPI bool `mapstructure:"serve_on_api"`
 }
 
 // https://github.com/metal-toolbox/fleetdb
@@ -0,0 +1,16 @@
+// Package componentkey identifies the components of a server inventory, to
+// match the components of one inventory with those of another.
+package componentkey
+
+import (
+	"strings"
+
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// Of returns the key identifying a component by slug and serial, slugs are
+// compared regardless of case. Components without a serial share the key of
+// their slug, they can't be told apart.
+func Of(c *rivets.Component) string {
+	return strings.ToLower(c.Name) + "/" + c.Serial
+}
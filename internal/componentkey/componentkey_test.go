@@ -0,0 +1,15 @@
+package componentkey
+
+import (
+	"testing"
+
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, Of(&rivets.Component{Name: "Drive", Serial: "d1"}), Of(&rivets.Component{Name: "drive", Serial: "d1"}))
+	require.NotEqual(t, Of(&rivets.Component{Name: "drive", Serial: "d1"}), Of(&rivets.Component{Name: "drive", Serial: "D1"}))
+	require.NotEqual(t, Of(&rivets.Component{Name: "drive", Serial: "d1"}), Of(&rivets.Component{Name: "nic", Serial: "d1"}))
+}
@@ -0,0 +1,76 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// canonicalServer holds the collected facts about a server. Identifiers and
+// timestamps assigned by the store are left out so that the same inventory
+// has the same fingerprint before and after it is stored, and so is the BIOS
+// configuration, which FleetDB doesn't return with an inventory.
+type canonicalServer struct {
+	Vendor     string              `json:"vendor,omitempty"`
+	Model      string              `json:"model,omitempty"`
+	Serial     string              `json:"serial,omitempty"`
+	Status     string              `json:"status,omitempty"`
+	Components []*rivets.Component `json:"components,omitempty"`
+}
+
+// Server returns a fingerprint of the inventory of a server.
+func Server(srv *rivets.Server) string {
+	return hash(&canonicalServer{
+		Vendor:     srv.Vendor,
+		Model:      srv.Model,
+		Serial:     srv.Serial,
+		Status:     srv.Status,
+		Components: canonicalComponents(srv.Components),
+	})
+}
+
+// Components returns a fingerprint of a set of components.
+func Components(cs []*rivets.Component) string {
+	return hash(canonicalComponents(cs))
+}
+
+// canonicalComponents returns copies of the components without their volatile
+// fields, ordered by slug and serial.
+func canonicalComponents(cs []*rivets.Component) []*rivets.Component {
+	out := make([]*rivets.Component, 0, len(cs))
+	for _, c := range cs {
+		if c == nil {
+			continue
+		}
+		copied := *c
+		copied.ID = ""
+		copied.UpdatedAt = time.Time{}
+		out = append(out, &copied)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Serial < out[j].Serial
+	})
+
+	return out
+}
+
+func hash(v any) string {
+	// encoding/json writes map keys in sorted order, which makes the encoding
+	// of the same value stable.
+	b, err := json.Marshal(v)
+	if err != nil {
+		// the rivets types always marshal
+		panic("unable to marshal inventory: " + err.Error())
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,40 @@
+package fingerprint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerIgnoresVolatileFields(t *testing.T) {
+	t.Parallel()
+	incoming := &rivets.Server{
+		Name:    "incoming",
+		BIOSCfg: map[string]string{"boot_mode": "uefi", "smt": "on"},
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "b"},
+			{Name: common.SlugCPU, Serial: "0"},
+		},
+	}
+	stored := &rivets.Server{
+		ID:        "3cbf6c6b-5bfa-4b26-9a2b-5e5d1b3b8d4f",
+		Name:      "stored",
+		UpdatedAt: time.Now(),
+		BIOSCfg:   map[string]string{"smt": "on", "boot_mode": "uefi"},
+		Components: []*rivets.Component{
+			{ID: "1", Name: common.SlugCPU, Serial: "0", UpdatedAt: time.Now()},
+			{ID: "2", Name: common.SlugDrive, Serial: "b", UpdatedAt: time.Now()},
+		},
+	}
+	require.Equal(t, Server(incoming), Server(stored))
+
+	// FleetDB doesn't return the BIOS configuration
+	stored.BIOSCfg = nil
+	require.Equal(t, Server(incoming), Server(stored))
+
+	stored.Components[1].Model = "new model"
+	require.NotEqual(t, Server(incoming), Server(stored))
+}
@@ -0,0 +1,145 @@
+package idempotency
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// State describes what is known about an idempotency key.
+type State int
+
+const (
+	// New means the key has not been seen, the caller should process the request
+	// and Complete or Abandon the key.
+	New State = iota
+	// InFlight means a request with the same key is still being processed.
+	InFlight
+	// Done means a request with the same key was processed and its response is available.
+	Done
+	// Mismatch means the key was used before for a different request.
+	Mismatch
+)
+
+// Response is the recorded outcome of a request.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	// Header holds the response headers to replay along with the body
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	response    *Response
+	expires     time.Time
+}
+
+// Store remembers the responses to requests by idempotency key for a window of
+// time so that repeated submissions of the same request get the original
+// response instead of being processed again. When it holds its maximum number
+// of keys, the least recently used one is forgotten.
+type Store struct {
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// most recently used first
+	order     *list.List
+	lastSweep time.Time
+}
+
+// NewStore returns a Store that keeps responses for the given window, for up
+// to maxEntries keys. A maxEntries of zero or less doesn't bound the keys.
+func NewStore(window time.Duration, maxEntries int) *Store {
+	return &Store{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Begin claims a key for a request identified by fingerprint. When the key was
+// already completed for the same request, the recorded response is returned.
+func (s *Store) Begin(key, fingerprint string) (State, *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	elem, ok := s.entries[key]
+	if !ok || now.After(elem.Value.(*entry).expires) {
+		if ok {
+			s.remove(elem)
+		}
+		s.entries[key] = s.order.PushFront(&entry{
+			key:         key,
+			fingerprint: fingerprint,
+			expires:     now.Add(s.window),
+		})
+		for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+			s.remove(s.order.Back())
+		}
+		return New, nil
+	}
+
+	s.order.MoveToFront(elem)
+	e := elem.Value.(*entry)
+	switch {
+	case e.fingerprint != fingerprint:
+		return Mismatch, nil
+	case e.response == nil:
+		return InFlight, nil
+	default:
+		return Done, e.response
+	}
+}
+
+// Complete records the response for a key claimed with Begin.
+func (s *Store) Complete(key string, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.response = resp
+		e.expires = time.Now().Add(s.window)
+	}
+}
+
+// Abandon releases a key claimed with Begin without recording a response, so
+// that the request can be retried.
+func (s *Store) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+}
+
+// sweep drops expired entries at most once per window. The caller must hold the lock.
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.window {
+		return
+	}
+
+	for _, elem := range s.entries {
+		if now.After(elem.Value.(*entry).expires) {
+			s.remove(elem)
+		}
+	}
+	s.lastSweep = now
+}
+
+// remove drops an entry. The caller must hold the lock.
+func (s *Store) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*entry).key)
+}
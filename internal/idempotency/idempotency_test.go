@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+	s := NewStore(time.Hour, 0)
+
+	state, _ := s.Begin("key", "body")
+	require.Equal(t, New, state)
+
+	state, _ = s.Begin("key", "body")
+	require.Equal(t, InFlight, state)
+
+	state, _ = s.Begin("key", "other body")
+	require.Equal(t, Mismatch, state)
+
+	s.Complete("key", &Response{StatusCode: http.StatusCreated})
+	state, resp := s.Begin("key", "body")
+	require.Equal(t, Done, state)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	state, _ = s.Begin("abandoned", "body")
+	require.Equal(t, New, state)
+	s.Abandon("abandoned")
+	state, _ = s.Begin("abandoned", "body")
+	require.Equal(t, New, state)
+}
+
+func TestStoreExpiry(t *testing.T) {
+	t.Parallel()
+	s := NewStore(time.Millisecond, 0)
+
+	state, _ := s.Begin("key", "body")
+	require.Equal(t, New, state)
+	s.Complete("key", &Response{StatusCode: http.StatusCreated})
+
+	time.Sleep(5 * time.Millisecond)
+	state, _ = s.Begin("key", "body")
+	require.Equal(t, New, state)
+}
+
+func TestStoreEviction(t *testing.T) {
+	t.Parallel()
+	s := NewStore(time.Hour, 2)
+
+	for _, key := range []string{"a", "b"} {
+		state, _ := s.Begin(key, "body")
+		require.Equal(t, New, state)
+		s.Complete(key, &Response{StatusCode: http.StatusCreated})
+	}
+
+	// using a makes b the least recently used key
+	state, _ := s.Begin("a", "body")
+	require.Equal(t, Done, state)
+
+	state, _ = s.Begin("c", "body")
+	require.Equal(t, New, state)
+
+	state, _ = s.Begin("a", "body")
+	require.Equal(t, Done, state)
+	state, _ = s.Begin("b", "body")
+	require.Equal(t, New, state, "b should have been evicted")
+	require.Len(t, s.entries, 2)
+}
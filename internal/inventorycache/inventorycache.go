@@ -0,0 +1,113 @@
+package inventorycache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+type key struct {
+	serverID uuid.UUID
+	inband   bool
+}
+
+type entry struct {
+	key     key
+	srv     *rivets.Server
+	expires time.Time
+}
+
+// Cache keeps the most recently read server inventories for a limited time.
+// Entries are only invalidated by this process, so with several replicas a read
+// may be as stale as the TTL. A nil Cache caches nothing.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[key]*list.Element
+	// most recently used first
+	order *list.List
+	now   func() time.Time
+}
+
+// NewCache returns a Cache holding up to size inventories for ttl each.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[key]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached inventory of a server, if there is a current one. The
+// returned server is shared and must not be modified.
+func (c *Cache) Get(serverID uuid.UUID, inband bool) (*rivets.Server, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key{serverID, inband}]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e.srv, true
+}
+
+// Set caches the inventory of a server, evicting the least recently used
+// inventory when the cache is full.
+func (c *Cache) Set(serverID uuid.UUID, inband bool, srv *rivets.Server) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key{serverID, inband}
+	e := &entry{key: k, srv: srv, expires: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[k]; ok {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[k] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops the cached inventory of a server.
+func (c *Cache) Invalidate(serverID uuid.UUID, inband bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key{serverID, inband}]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
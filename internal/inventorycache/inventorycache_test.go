@@ -0,0 +1,55 @@
+package inventorycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	c := NewCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	srv := &rivets.Server{Name: "first"}
+
+	c.Set(first, true, srv)
+	got, ok := c.Get(first, true)
+	require.True(t, ok)
+	require.Same(t, srv, got)
+
+	// modes are cached separately
+	_, ok = c.Get(first, false)
+	require.False(t, ok)
+
+	// the least recently used inventory is evicted
+	c.Set(second, true, &rivets.Server{})
+	_, ok = c.Get(first, true)
+	require.True(t, ok)
+	c.Set(third, true, &rivets.Server{})
+	_, ok = c.Get(second, true)
+	require.False(t, ok)
+
+	c.Invalidate(first, true)
+	_, ok = c.Get(first, true)
+	require.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get(third, true)
+	require.False(t, ok)
+	require.Empty(t, c.entries)
+}
+
+func TestNilCache(t *testing.T) {
+	t.Parallel()
+	var c *Cache
+	c.Set(uuid.New(), true, &rivets.Server{})
+	_, ok := c.Get(uuid.New(), true)
+	require.False(t, ok)
+	c.Invalidate(uuid.New(), true)
+}
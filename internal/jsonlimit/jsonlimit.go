@@ -0,0 +1,73 @@
+// Package jsonlimit bounds the structure of JSON documents before they are
+// decoded, as decoding a document allocates for every value in it.
+package jsonlimit
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTooDeep is returned for documents nesting arrays and objects deeper
+	// than allowed.
+	ErrTooDeep = errors.New("JSON nested too deeply")
+	// ErrTooManyElements is returned for documents with an array or object
+	// holding more elements than allowed.
+	ErrTooManyElements = errors.New("JSON array or object too large")
+)
+
+// Limits bound a JSON document, zero values leave it unbounded.
+type Limits struct {
+	// MaxDepth is how deep arrays and objects may be nested
+	MaxDepth int
+	// MaxElements is how many elements an array, or members an object, may have
+	MaxElements int
+}
+
+// Check scans a JSON document for arrays and objects exceeding the limits. It
+// doesn't validate the document, decoding it does.
+func (l Limits) Check(data []byte) error {
+	// the number of commas seen in each array or object being scanned
+	var commas []int
+	inString, escaped := false, false
+
+	for i, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			commas = append(commas, 0)
+			if l.MaxDepth > 0 && len(commas) > l.MaxDepth {
+				return fmt.Errorf("%w: more than %d levels at offset %d", ErrTooDeep, l.MaxDepth, i)
+			}
+		case ']', '}':
+			if len(commas) > 0 {
+				commas = commas[:len(commas)-1]
+			}
+		case ',':
+			if len(commas) == 0 {
+				continue
+			}
+			last := len(commas) - 1
+			commas[last]++
+			// elements are one more than the commas between them
+			if l.MaxElements > 0 && commas[last] >= l.MaxElements {
+				return fmt.Errorf("%w: more than %d elements at offset %d", ErrTooManyElements, l.MaxElements, i)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package jsonlimit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	limits := Limits{MaxDepth: 4, MaxElements: 3}
+
+	tests := []struct {
+		name string
+		doc  string
+		err  error
+	}{
+		{name: "empty", doc: ``},
+		{name: "scalar", doc: `"inventory"`},
+		{name: "within limits", doc: `{"inventory": {"drives": [{"serial": "a"}, {"serial": "b"}, {}]}}`},
+		{name: "empty containers", doc: `[[], {}, [[]]]`},
+		{name: "too deep", doc: `{"a": {"b": {"c": {"d": {}}}}}`, err: ErrTooDeep},
+		{name: "too many elements", doc: `[1, 2, 3, 4]`, err: ErrTooManyElements},
+		{name: "too many members", doc: `{"metadata": {"a": "1", "b": "2", "c": "3", "d": "4"}}`, err: ErrTooManyElements},
+		{name: "brackets in strings", doc: `{"a": "[[[[[[", "b": "{{{{{{", "c": ",,,,,,"}`},
+		{name: "escaped quotes", doc: `{"a": "\"[[[[[[\\", "b": "\\\"{{{{{{"}`},
+		{name: "deep after string", doc: `["\\", [[[[]]]]]`, err: ErrTooDeep},
+		{name: "unbalanced", doc: `]]]]}}}} [1, 2, 3, 4`, err: ErrTooManyElements},
+		{name: "nesting bomb", doc: strings.Repeat("[", 1<<20), err: ErrTooDeep},
+		{name: "array bomb", doc: "[" + strings.Repeat("0,", 1<<20) + "0]", err: ErrTooManyElements},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := limits.Check([]byte(tc.doc))
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.NoError(t, Limits{}.Check([]byte(strings.Repeat("[", 1<<10))), "zero limits leave documents unbounded")
+}
@@ -0,0 +1,285 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/metal-toolbox/component-inventory/internal/componentkey"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// Policy decides what a component field is stored as, given its submitted and
+// existing values.
+type Policy string
+
+const (
+	// Replace stores the submitted value, even when it is empty
+	Replace Policy = "replace"
+	// KeepExistingIfEmpty stores the existing value when none was submitted
+	KeepExistingIfEmpty Policy = "keep-existing-if-empty"
+	// Union stores the submitted entries of lists and maps along with the
+	// existing entries not submitted. Other fields are kept as with
+	// KeepExistingIfEmpty.
+	Union Policy = "union"
+)
+
+// Any matches every slug or every field in a Rule.
+const Any = "*"
+
+// ErrInvalidRule is returned for rules that can't be applied.
+var ErrInvalidRule = errors.New("invalid merge rule")
+
+// Rule sets the policy of a field of the components with a slug. Fields are
+// named by their JSON keys, e.g. "firmware" or "attributes.smart_status";
+// naming "attributes" covers all of them.
+type Rule struct {
+	Slug   string
+	Field  string
+	Policy Policy
+}
+
+// Policies are the rules applied when a server inventory is stored. A nil
+// *Policies replaces every field.
+type Policies struct {
+	rules []Rule
+}
+
+// field is a component field rules can name.
+type field struct {
+	path string
+	// attribute fields are in the component Attributes
+	attribute bool
+	index     int
+}
+
+var fields = componentFields()
+
+// New validates rules and returns the Policies applying them. Rules naming a
+// slug take precedence over rules for Any slug, then rules naming a field
+// more precisely take precedence.
+func New(rules []Rule) (*Policies, error) {
+	for _, r := range rules {
+		switch r.Policy {
+		case Replace, KeepExistingIfEmpty, Union:
+		default:
+			return nil, fmt.Errorf("%w: unknown policy %q", ErrInvalidRule, r.Policy)
+		}
+
+		if r.Slug == "" {
+			return nil, fmt.Errorf("%w: a slug or %q is required", ErrInvalidRule, Any)
+		}
+
+		if !knownField(r.Field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidRule, r.Field)
+		}
+	}
+
+	return &Policies{rules: append([]Rule(nil), rules...)}, nil
+}
+
+// Apply merges the components of existing into the matching components of
+// latest, by slug and serial, according to the policies. Components only in
+// existing are not added, nor are components without a serial merged.
+func (p *Policies) Apply(existing, latest *rivets.Server) {
+	if p == nil || len(p.rules) == 0 || existing == nil || latest == nil {
+		return
+	}
+
+	stored := make(map[string]*rivets.Component, len(existing.Components))
+	for _, c := range existing.Components {
+		// without a serial, any of the existing components could be its match
+		if c.Serial != "" {
+			stored[componentkey.Of(c)] = c
+		}
+	}
+
+	for _, c := range latest.Components {
+		if prev, ok := stored[componentkey.Of(c)]; ok {
+			p.mergeComponent(prev, c)
+		}
+	}
+}
+
+func (p *Policies) mergeComponent(existing, latest *rivets.Component) {
+	for _, f := range fields {
+		policy := p.policy(latest.Name, f.path)
+		if policy == Replace {
+			continue
+		}
+
+		if !f.attribute {
+			mergeValue(reflect.ValueOf(existing).Elem().Field(f.index), reflect.ValueOf(latest).Elem().Field(f.index), policy)
+			continue
+		}
+
+		if existing.Attributes == nil {
+			continue
+		}
+
+		prev := reflect.ValueOf(existing.Attributes).Elem().Field(f.index)
+		if prev.IsZero() {
+			continue
+		}
+
+		if latest.Attributes == nil {
+			latest.Attributes = &rivets.ComponentAttributes{}
+		}
+		mergeValue(prev, reflect.ValueOf(latest.Attributes).Elem().Field(f.index), policy)
+	}
+}
+
+// policy returns the policy of the most specific rule matching a field of the
+// components with slug.
+func (p *Policies) policy(slug, path string) Policy {
+	policy, best := Replace, -1
+	for _, r := range p.rules {
+		if !strings.EqualFold(r.Slug, slug) && r.Slug != Any {
+			continue
+		}
+
+		if r.Field != Any && r.Field != path && !strings.HasPrefix(path, r.Field+".") {
+			continue
+		}
+
+		// slug matches rank above any field match
+		rank := len(r.Field)
+		if r.Field == Any {
+			rank = 0
+		}
+		if r.Slug != Any {
+			rank += 1 << 16
+		}
+
+		if rank > best {
+			policy, best = r.Policy, rank
+		}
+	}
+	return policy
+}
+
+func mergeValue(existing, latest reflect.Value, policy Policy) {
+	if empty(existing) {
+		return
+	}
+
+	if empty(latest) {
+		latest.Set(existing)
+		return
+	}
+
+	// structs like the firmware and status are merged field by field, on a
+	// copy as the submitted one may be shared
+	if latest.Kind() == reflect.Pointer && latest.Elem().Kind() == reflect.Struct {
+		merged := reflect.New(latest.Elem().Type())
+		merged.Elem().Set(latest.Elem())
+		for i := 0; i < merged.Elem().NumField(); i++ {
+			if f := merged.Elem().Field(i); f.CanSet() {
+				mergeValue(existing.Elem().Field(i), f, policy)
+			}
+		}
+		latest.Set(merged)
+		return
+	}
+
+	if policy != Union {
+		return
+	}
+
+	switch latest.Kind() {
+	case reflect.Slice:
+		merged := reflect.AppendSlice(reflect.MakeSlice(latest.Type(), 0, latest.Len()+existing.Len()), latest)
+		for i := 0; i < existing.Len(); i++ {
+			if !contains(merged, existing.Index(i)) {
+				merged = reflect.Append(merged, existing.Index(i))
+			}
+		}
+		latest.Set(merged)
+	case reflect.Map:
+		merged := reflect.MakeMapWithSize(latest.Type(), latest.Len()+existing.Len())
+		for iter := existing.MapRange(); iter.Next(); {
+			merged.SetMapIndex(iter.Key(), iter.Value())
+		}
+		for iter := latest.MapRange(); iter.Next(); {
+			merged.SetMapIndex(iter.Key(), iter.Value())
+		}
+		latest.Set(merged)
+	}
+}
+
+// empty reports whether v holds no data: a zero value, an empty list or map,
+// or a pointer to a struct with only empty fields, e.g. &common.Firmware{}.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v.IsZero()
+		}
+		for i := 0; i < v.Elem().NumField(); i++ {
+			if f := v.Elem().Field(i); f.CanInterface() && !empty(f) {
+				return false
+			}
+		}
+		return true
+	}
+	return v.IsZero()
+}
+
+func contains(list, v reflect.Value) bool {
+	for i := 0; i < list.Len(); i++ {
+		if reflect.DeepEqual(list.Index(i).Interface(), v.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// componentFields lists the fields of a component rules can name, leaving out
+// those identifying it.
+func componentFields() []field {
+	var out []field
+
+	ct := reflect.TypeOf(rivets.Component{})
+	for i := 0; i < ct.NumField(); i++ {
+		name := jsonName(ct.Field(i))
+		switch name {
+		case "", "id", "updated", "name", "serial":
+			continue
+		case "attributes":
+			at := ct.Field(i).Type.Elem()
+			for j := 0; j < at.NumField(); j++ {
+				if attr := jsonName(at.Field(j)); attr != "" {
+					out = append(out, field{path: name + "." + attr, attribute: true, index: j})
+				}
+			}
+			continue
+		}
+		out = append(out, field{path: name, index: i})
+	}
+
+	return out
+}
+
+func knownField(name string) bool {
+	if name == Any {
+		return true
+	}
+
+	for _, f := range fields {
+		if f.path == name || strings.HasPrefix(f.path, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
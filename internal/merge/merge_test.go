@@ -0,0 +1,131 @@
+package merge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+	for _, r := range []Rule{
+		{Slug: Any, Field: Any, Policy: "merge"},
+		{Slug: "", Field: Any, Policy: Replace},
+		{Slug: common.SlugDrive, Field: "attributes.smart", Policy: Union},
+		{Slug: common.SlugDrive, Field: "serial", Policy: Union},
+	} {
+		_, err := New([]Rule{r})
+		require.True(t, errors.Is(err, ErrInvalidRule), "%+v", r)
+	}
+
+	_, err := New([]Rule{
+		{Slug: Any, Field: "attributes", Policy: KeepExistingIfEmpty},
+		{Slug: common.SlugDrive, Field: "attributes.smart_errors", Policy: Union},
+	})
+	require.NoError(t, err)
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{Components: []*rivets.Component{
+		{
+			Name:   common.SlugDrive,
+			Serial: "d1",
+			Model:  "old model",
+			Attributes: &rivets.ComponentAttributes{
+				SmartStatus: "ok",
+				SmartErrors: []string{"a", "b"},
+				Metadata:    map[string]string{"slot": "1", "bay": "2"},
+			},
+		},
+		{Name: common.SlugNIC, Serial: "n1", Vendor: "intel"},
+	}}
+	latest := &rivets.Server{Components: []*rivets.Component{
+		{
+			Name:   common.SlugDrive,
+			Serial: "d1",
+			Attributes: &rivets.ComponentAttributes{
+				SmartErrors: []string{"b", "c"},
+				Metadata:    map[string]string{"slot": "3"},
+			},
+		},
+		{Name: common.SlugNIC, Serial: "n1"},
+		{Name: common.SlugNIC, Serial: "n2"},
+	}}
+
+	policies, err := New([]Rule{
+		{Slug: Any, Field: "attributes", Policy: KeepExistingIfEmpty},
+		{Slug: common.SlugDrive, Field: "attributes.smart_errors", Policy: Union},
+		{Slug: common.SlugDrive, Field: "attributes.metadata", Policy: Union},
+		{Slug: common.SlugNIC, Field: Any, Policy: KeepExistingIfEmpty},
+	})
+	require.NoError(t, err)
+	policies.Apply(existing, latest)
+
+	drive := latest.Components[0]
+	require.Empty(t, drive.Model, "fields without a rule are replaced")
+	require.Equal(t, "ok", drive.Attributes.SmartStatus)
+	require.Equal(t, []string{"b", "c", "a"}, drive.Attributes.SmartErrors)
+	require.Equal(t, map[string]string{"slot": "3", "bay": "2"}, drive.Attributes.Metadata)
+
+	require.Equal(t, "intel", latest.Components[1].Vendor)
+	require.Empty(t, latest.Components[2].Vendor)
+	require.Nil(t, latest.Components[2].Attributes)
+}
+
+func TestNilPoliciesReplace(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{Components: []*rivets.Component{{Name: common.SlugCPU, Serial: "0", Model: "x"}}}
+	latest := &rivets.Server{Components: []*rivets.Component{{Name: common.SlugCPU, Serial: "0"}}}
+
+	var policies *Policies
+	policies.Apply(existing, latest)
+	require.Empty(t, latest.Components[0].Model)
+}
+
+func TestApplySkipsComponentsWithoutSerial(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{Components: []*rivets.Component{
+		{Name: common.SlugPhysicalMem, Vendor: "samsung"},
+		{Name: common.SlugPhysicalMem, Vendor: "micron"},
+	}}
+	latest := &rivets.Server{Components: []*rivets.Component{
+		{Name: common.SlugPhysicalMem},
+		{Name: common.SlugPhysicalMem},
+	}}
+
+	policies, err := New([]Rule{{Slug: Any, Field: Any, Policy: KeepExistingIfEmpty}})
+	require.NoError(t, err)
+	policies.Apply(existing, latest)
+
+	for _, c := range latest.Components {
+		require.Empty(t, c.Vendor)
+	}
+}
+
+func TestApplyEmptyStructs(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{Components: []*rivets.Component{{
+		Name:     common.SlugBMC,
+		Serial:   "b1",
+		Firmware: &common.Firmware{Installed: "6.10", Available: "7.00"},
+		Status:   &common.Status{Health: "OK", State: "Enabled"},
+	}}}
+	latest := &rivets.Server{Components: []*rivets.Component{{
+		Name:     common.SlugBMC,
+		Serial:   "b1",
+		Firmware: common.NewFirmwareObj(),
+		Status:   &common.Status{State: "StandbyOffline"},
+	}}}
+
+	policies, err := New([]Rule{{Slug: Any, Field: Any, Policy: KeepExistingIfEmpty}})
+	require.NoError(t, err)
+	policies.Apply(existing, latest)
+
+	bmc := latest.Components[0]
+	require.Equal(t, &common.Firmware{Installed: "6.10", Available: "7.00"}, bmc.Firmware)
+	require.Equal(t, &common.Status{Health: "OK", State: "StandbyOffline"}, bmc.Status)
+}
@@ -1,24 +1,63 @@
 package metrics
 
 import (
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/metal-toolbox/component-inventory/internal/app"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Endpoint is the path metrics are served on
+const Endpoint = "/metrics"
+
 var (
-	apiLatencySeconds    *prometheus.HistogramVec
-	dependencyErrorCount *prometheus.CounterVec
+	// registry holds all the metrics of this service. It is used instead of the
+	// prometheus default registry so that nothing registers metrics behind our back.
+	registry = prometheus.NewRegistry()
+
+	apiLatencySeconds        *prometheus.HistogramVec
+	dependencyErrorCount     *prometheus.CounterVec
+	dependencyLatencySeconds *prometheus.HistogramVec
+	ingestionCount           *prometheus.CounterVec
+	componentChangeCount     *prometheus.CounterVec
+	conversionFailureCount   *prometheus.CounterVec
+	cacheLookupCount         *prometheus.CounterVec
+	throttledCount           *prometheus.CounterVec
+)
+
+// Ingestion outcomes
+const (
+	IngestionProcessed = "processed"
+	IngestionUnchanged = "unchanged"
+	IngestionRejected  = "rejected"
+)
+
+// Cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Component change kinds
+const (
+	ComponentAdded   = "added"
+	ComponentRemoved = "removed"
+	ComponentChanged = "changed"
 )
 
 func init() {
-	dependencyErrorCount = promauto.NewCounterVec(
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	factory := promauto.With(registry)
+	dependencyErrorCount = factory.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: app.AppName,
 			Subsystem: "dependencies",
@@ -29,7 +68,74 @@ func init() {
 			"operation",
 		},
 	)
-	apiLatencySeconds = promauto.NewHistogramVec(
+	dependencyLatencySeconds = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: app.AppName,
+			Subsystem: "dependencies",
+			Name:      "latency_seconds",
+			Help:      "latency of calls to " + app.AppName + " dependencies in seconds",
+			// buckets between 10ms to 10 s
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}, []string{
+			"dependency_name",
+			"operation",
+		},
+	)
+	ingestionCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: app.AppName,
+			Subsystem: "inventory",
+			Name:      "ingestions_total",
+			Help:      "a count of inventory submissions by collection mode and outcome",
+		}, []string{
+			"mode",
+			"outcome",
+		},
+	)
+	componentChangeCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: app.AppName,
+			Subsystem: "inventory",
+			Name:      "component_changes_total",
+			Help:      "a count of components added, removed or changed by ingestions",
+		}, []string{
+			"mode",
+			"slug",
+			"change",
+		},
+	)
+	conversionFailureCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: app.AppName,
+			Subsystem: "inventory",
+			Name:      "conversion_failures_total",
+			Help:      "a count of inventory payloads that could not be converted",
+		}, []string{
+			"format",
+		},
+	)
+	cacheLookupCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: app.AppName,
+			Subsystem: "cache",
+			Name:      "lookups_total",
+			Help:      "a count of cache lookups by cache and result",
+		}, []string{
+			"cache",
+			"result",
+		},
+	)
+	throttledCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: app.AppName,
+			Subsystem: "api",
+			Name:      "throttled_requests_total",
+			Help:      "a count of requests rejected for exceeding a rate limit",
+		}, []string{
+			"limit",
+		},
+	)
+	apiLatencySeconds = factory.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: app.AppName,
 			Subsystem: "api",
@@ -45,22 +151,22 @@ func init() {
 	)
 }
 
-// ListenAndServeMetrics exposes prometheus metrics as /metrics on port 9090
-func ListenAndServe() {
-	endpoint := "0.0.0.0:9090"
-
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
+// Handler returns an http.Handler that exposes the metrics registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
 
-		server := &http.Server{
-			Addr:              endpoint,
-			ReadHeaderTimeout: 2 * time.Second,
-		}
+// NewServer returns an http.Server exposing the metrics as /metrics on the given address.
+// The caller is responsible for starting and shutting down the server.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(Endpoint, Handler())
 
-		if err := server.ListenAndServe(); err != nil {
-			log.Println(err)
-		}
-	}()
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 2 * time.Second,
+	}
 }
 
 // DependencyError provides a convenience method to hide some prometheus implementation
@@ -69,6 +175,41 @@ func DependencyError(name, operation string) {
 	dependencyErrorCount.WithLabelValues(name, operation).Inc()
 }
 
+// DependencyCallEpilog observes the latency of a call to a dependency
+func DependencyCallEpilog(start time.Time, name, operation string) {
+	elapsed := time.Since(start).Seconds()
+	dependencyLatencySeconds.WithLabelValues(name, operation).Observe(elapsed)
+}
+
+// Ingestion counts an inventory submission with the given outcome
+func Ingestion(mode, outcome string) {
+	ingestionCount.WithLabelValues(mode, outcome).Inc()
+}
+
+// ComponentChanges counts components of a slug that were added, removed or changed
+func ComponentChanges(mode, slug, change string, count int) {
+	componentChangeCount.WithLabelValues(mode, slug, change).Add(float64(count))
+}
+
+// ConversionFailure counts a payload of the given format that failed conversion
+func ConversionFailure(format string) {
+	conversionFailureCount.WithLabelValues(format).Inc()
+}
+
+// CacheLookup counts a lookup in the named cache
+func CacheLookup(cache string, hit bool) {
+	result := CacheMiss
+	if hit {
+		result = CacheHit
+	}
+	cacheLookupCount.WithLabelValues(cache, result).Inc()
+}
+
+// Throttled counts a request rejected by the named rate limit
+func Throttled(limit string) {
+	throttledCount.WithLabelValues(limit).Inc()
+}
+
 // APICallEpilog observes the results and latency of an API call
 func APICallEpilog(start time.Time, endpoint string, responseCode int) {
 	code := strconv.Itoa(responseCode)
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// how often buckets of clients that went quiet are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per client key.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	buckets   map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter returns a Limiter allowing each client perSecond requests on
+// average, with bursts of up to burst requests.
+func NewLimiter(perSecond float64, burst int) *Limiter {
+	// a bucket unused for as long as it takes to refill is the same as a new one
+	idle := time.Duration(float64(burst) / perSecond * float64(time.Second))
+	if idle < sweepInterval {
+		idle = sweepInterval
+	}
+
+	return &Limiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		buckets: map[string]*bucket{},
+		idle:    idle,
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the given client. When the bucket is
+// empty it returns false and how long the client should wait before retrying.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, l.idle
+	}
+
+	if delay := r.DelayFrom(now); delay > 0 {
+		// the client won't wait for the token, give it back
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	l := NewLimiter(1, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := l.Allow("collector")
+		require.True(t, ok)
+	}
+
+	ok, retryAfter := l.Allow("collector")
+	require.False(t, ok)
+	require.Equal(t, time.Second, retryAfter)
+
+	// clients have their own buckets
+	ok, _ = l.Allow("dashboard")
+	require.True(t, ok)
+
+	// throttled requests don't use up tokens
+	now = now.Add(time.Second)
+	ok, _ = l.Allow("collector")
+	require.True(t, ok)
+
+	now = now.Add(2 * sweepInterval)
+	l.Allow("dashboard")
+	require.Len(t, l.buckets, 1)
+}
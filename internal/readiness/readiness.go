@@ -0,0 +1,163 @@
+package readiness
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Check verifies that a dependency is usable. It returns nil when it is.
+type Check func(ctx context.Context) error
+
+// DependencyStatus is the outcome of the last check of a dependency.
+type DependencyStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker runs a set of named dependency checks and caches their results so
+// that frequent probes don't translate into load on the dependencies. Checks
+// run without holding up probes: a probe arriving while they run waits for
+// them only as long as its context allows.
+type Checker struct {
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	names   []string
+	checks  map[string]Check
+	results map[string]*DependencyStatus
+	lastRun time.Time
+	// running is closed when the checks being run are done, nil when none are
+	running chan struct{}
+}
+
+// NewChecker returns a Checker that reuses results for ttl and bounds each
+// round of checks by timeout.
+func NewChecker(ttl, timeout time.Duration) *Checker {
+	return &Checker{
+		ttl:     ttl,
+		timeout: timeout,
+		checks:  map[string]Check{},
+		results: map[string]*DependencyStatus{},
+	}
+}
+
+// Add registers a named check.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+		sort.Strings(c.names)
+	}
+	c.checks[name] = check
+	c.lastRun = time.Time{}
+}
+
+// Check returns the status of each dependency and whether all of them are ready.
+// Cached results are returned when they are younger than the ttl. When ctx is
+// done before the checks are, the previous results are returned.
+func (c *Checker) Check(ctx context.Context) (bool, map[string]*DependencyStatus) {
+	c.mu.Lock()
+	running := c.running
+	if running == nil && time.Since(c.lastRun) > c.ttl {
+		running = make(chan struct{})
+		c.running = running
+		checks := make(map[string]Check, len(c.checks))
+		for name, check := range c.checks {
+			checks[name] = check
+		}
+		// the checks outlive a probe that gives up on them
+		go c.run(context.WithoutCancel(ctx), checks, running)
+	}
+	c.mu.Unlock()
+
+	if running != nil {
+		select {
+		case <-running:
+		case <-ctx.Done():
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ready := len(c.results) == len(c.checks)
+	statuses := make(map[string]*DependencyStatus, len(c.results))
+	for name, res := range c.results {
+		if res.Status != StatusOK {
+			ready = false
+		}
+		copied := *res
+		statuses[name] = &copied
+	}
+	return ready, statuses
+}
+
+// run executes checks concurrently and records their results, closing done
+// once it has. Checks still running when the timeout is up are failed.
+func (c *Checker) run(ctx context.Context, checks map[string]Check, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var resMu sync.Mutex
+	results := make(map[string]*DependencyStatus, len(checks))
+	finished := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			res := &DependencyStatus{Status: StatusOK}
+			if err := check(ctx); err != nil {
+				res.Status = StatusFailed
+				res.Error = err.Error()
+			}
+			res.CheckedAt = time.Now()
+
+			resMu.Lock()
+			results[name] = res
+			resMu.Unlock()
+		}(name, check)
+	}
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	// a check ignoring its context doesn't hold up the others
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+
+	resMu.Lock()
+	recorded := make(map[string]*DependencyStatus, len(checks))
+	for name := range checks {
+		res, ok := results[name]
+		if !ok {
+			res = &DependencyStatus{Status: StatusFailed, Error: ctx.Err().Error(), CheckedAt: time.Now()}
+		}
+		recorded[name] = res
+	}
+	resMu.Unlock()
+
+	c.mu.Lock()
+	c.results = recorded
+	c.lastRun = time.Now()
+	c.running = nil
+	c.mu.Unlock()
+	close(done)
+}
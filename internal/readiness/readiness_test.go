@@ -0,0 +1,89 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerCachesResults(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	c := NewChecker(time.Hour, time.Second)
+	c.Add("fleetdb", func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	ready, statuses := c.Check(context.Background())
+	require.True(t, ready)
+	require.Equal(t, StatusOK, statuses["fleetdb"].Status)
+
+	_, _ = c.Check(context.Background())
+	require.Equal(t, 1, calls)
+}
+
+func TestCheckerReportsFailures(t *testing.T) {
+	t.Parallel()
+	c := NewChecker(0, time.Second)
+	c.Add("fleetdb", func(context.Context) error { return nil })
+	c.Add("oidc", func(context.Context) error { return errors.New("no token for you") })
+
+	ready, statuses := c.Check(context.Background())
+	require.False(t, ready)
+	require.Equal(t, StatusOK, statuses["fleetdb"].Status)
+	require.Equal(t, StatusFailed, statuses["oidc"].Status)
+	require.Equal(t, "no token for you", statuses["oidc"].Error)
+}
+
+func TestCheckerFailsHungChecks(t *testing.T) {
+	t.Parallel()
+	hung := make(chan struct{})
+	defer close(hung)
+
+	c := NewChecker(time.Hour, 10*time.Millisecond)
+	c.Add("fleetdb", func(context.Context) error { return nil })
+	c.Add("oidc", func(context.Context) error {
+		// ignores its context
+		<-hung
+		return nil
+	})
+
+	ready, statuses := c.Check(context.Background())
+	require.False(t, ready)
+	require.Equal(t, StatusOK, statuses["fleetdb"].Status)
+	require.Equal(t, StatusFailed, statuses["oidc"].Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), statuses["oidc"].Error)
+}
+
+func TestCheckerDoesNotBlockProbes(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	c := NewChecker(time.Hour, time.Minute)
+	c.Add("oidc", func(ctx context.Context) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	// probes give up on running checks when their context is done, and
+	// don't start the checks again
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		ready, statuses := c.Check(ctx)
+		cancel()
+		require.False(t, ready)
+		require.Empty(t, statuses)
+	}
+
+	close(release)
+	ready, statuses := c.Check(context.Background())
+	require.True(t, ready)
+	require.Equal(t, StatusOK, statuses["oidc"].Status)
+}
@@ -0,0 +1,134 @@
+package redfishconverter
+
+// The Redfish resources a dump is made of. Only the properties the inventory
+// uses are decoded, collections are expected with their members expanded.
+
+// Dump holds the Redfish resources describing a single system, as exported
+// from its BMC.
+type Dump struct {
+	Systems         []*System         `json:"Systems"`
+	Chassis         []*Chassis        `json:"Chassis,omitempty"`
+	Managers        []*Manager        `json:"Managers,omitempty"`
+	Storage         []*Storage        `json:"Storage,omitempty"`
+	Memory          []*Memory         `json:"Memory,omitempty"`
+	Processors      []*Processor      `json:"Processors,omitempty"`
+	NetworkAdapters []*NetworkAdapter `json:"NetworkAdapters,omitempty"`
+	// Bios is the BIOS resource of the system, its attributes are the BIOS configuration
+	Bios *Bios `json:"Bios,omitempty"`
+}
+
+// Status is the Redfish status of a resource.
+type Status struct {
+	State  string `json:"State,omitempty"`
+	Health string `json:"Health,omitempty"`
+}
+
+// Resource holds the properties common to the physical resources.
+type Resource struct {
+	ID           string  `json:"Id,omitempty"`
+	Name         string  `json:"Name,omitempty"`
+	Description  string  `json:"Description,omitempty"`
+	Manufacturer string  `json:"Manufacturer,omitempty"`
+	Model        string  `json:"Model,omitempty"`
+	SerialNumber string  `json:"SerialNumber,omitempty"`
+	PartNumber   string  `json:"PartNumber,omitempty"`
+	Status       *Status `json:"Status,omitempty"`
+}
+
+// System is a ComputerSystem resource.
+type System struct {
+	Resource
+	SKU         string `json:"SKU,omitempty"`
+	BiosVersion string `json:"BiosVersion,omitempty"`
+}
+
+// Chassis is a Chassis resource.
+type Chassis struct {
+	Resource
+	ChassisType string `json:"ChassisType,omitempty"`
+	// PowerSupplies are the power supplies of the Power resource of the chassis
+	PowerSupplies []*PowerSupply `json:"PowerSupplies,omitempty"`
+}
+
+// PowerSupply is a power supply of a Power resource.
+type PowerSupply struct {
+	Resource
+	MemberID           string  `json:"MemberId,omitempty"`
+	FirmwareVersion    string  `json:"FirmwareVersion,omitempty"`
+	PowerCapacityWatts float64 `json:"PowerCapacityWatts,omitempty"`
+}
+
+// Manager is a Manager resource, e.g. a BMC.
+type Manager struct {
+	Resource
+	ManagerType     string `json:"ManagerType,omitempty"`
+	FirmwareVersion string `json:"FirmwareVersion,omitempty"`
+}
+
+// Storage is a Storage resource with its controllers and drives.
+type Storage struct {
+	Resource
+	StorageControllers []*StorageController `json:"StorageControllers,omitempty"`
+	Drives             []*Drive             `json:"Drives,omitempty"`
+}
+
+// StorageController is a storage controller of a Storage resource.
+type StorageController struct {
+	Resource
+	MemberID                     string   `json:"MemberId,omitempty"`
+	FirmwareVersion              string   `json:"FirmwareVersion,omitempty"`
+	SpeedGbps                    float64  `json:"SpeedGbps,omitempty"`
+	SupportedControllerProtocols []string `json:"SupportedControllerProtocols,omitempty"`
+	SupportedDeviceProtocols     []string `json:"SupportedDeviceProtocols,omitempty"`
+	SupportedRAIDTypes           []string `json:"SupportedRAIDTypes,omitempty"`
+}
+
+// Drive is a Drive resource.
+type Drive struct {
+	Resource
+	MediaType          string  `json:"MediaType,omitempty"`
+	Protocol           string  `json:"Protocol,omitempty"`
+	Revision           string  `json:"Revision,omitempty"`
+	CapacityBytes      int64   `json:"CapacityBytes,omitempty"`
+	BlockSizeBytes     int64   `json:"BlockSizeBytes,omitempty"`
+	CapableSpeedGbs    float64 `json:"CapableSpeedGbs,omitempty"`
+	NegotiatedSpeedGbs float64 `json:"NegotiatedSpeedGbs,omitempty"`
+}
+
+// Memory is a Memory resource, e.g. a DIMM.
+type Memory struct {
+	Resource
+	DeviceLocator     string `json:"DeviceLocator,omitempty"`
+	MemoryDeviceType  string `json:"MemoryDeviceType,omitempty"`
+	BaseModuleType    string `json:"BaseModuleType,omitempty"`
+	CapacityMiB       int64  `json:"CapacityMiB,omitempty"`
+	OperatingSpeedMhz int64  `json:"OperatingSpeedMhz,omitempty"`
+	FirmwareRevision  string `json:"FirmwareRevision,omitempty"`
+}
+
+// Processor is a Processor resource, a CPU or a GPU.
+type Processor struct {
+	Resource
+	Socket         string `json:"Socket,omitempty"`
+	ProcessorType  string `json:"ProcessorType,omitempty"`
+	InstructionSet string `json:"InstructionSet,omitempty"`
+	MaxSpeedMHz    int64  `json:"MaxSpeedMHz,omitempty"`
+	TotalCores     int    `json:"TotalCores,omitempty"`
+	TotalThreads   int    `json:"TotalThreads,omitempty"`
+}
+
+// NetworkAdapter is a NetworkAdapter resource.
+type NetworkAdapter struct {
+	Resource
+	Controllers []*NetworkController `json:"Controllers,omitempty"`
+}
+
+// NetworkController is a controller of a NetworkAdapter resource.
+type NetworkController struct {
+	FirmwarePackageVersion string `json:"FirmwarePackageVersion,omitempty"`
+}
+
+// Bios is a Bios resource.
+type Bios struct {
+	Attributes map[string]any `json:"Attributes,omitempty"`
+}
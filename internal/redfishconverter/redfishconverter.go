@@ -0,0 +1,276 @@
+package redfishconverter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+	iconv "github.com/metal-toolbox/component-inventory/internal/inventoryconverter"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+const (
+	// the resource state of empty slots
+	stateAbsent = "Absent"
+
+	mebibyte  = 1 << 20
+	megahertz = 1000 * 1000
+)
+
+// Errors for dumps that don't describe exactly one system.
+var (
+	ErrNoSystem       = errors.New("redfish dump has no system")
+	ErrTooManySystems = errors.New("redfish dump has more than one system")
+)
+
+// ToRivetsServer converts a Redfish dump into a rivets.Server, with the same
+// components ToRivetsServer of inventoryconverter makes from an Alloy inventory.
+func ToRivetsServer(serverID, facility string, dump *Dump) (*rivets.Server, error) {
+	device, err := ToDevice(dump)
+	if err != nil {
+		return nil, err
+	}
+
+	return iconv.ToRivetsServer(serverID, facility, device, biosConfig(dump.Bios)), nil
+}
+
+// ToDevice converts a Redfish dump into the device model of Alloy inventories.
+// Null entries of the resource lists are skipped.
+func ToDevice(dump *Dump) (*common.Device, error) {
+	if dump == nil {
+		return nil, ErrNoSystem
+	}
+
+	var systems []*System
+	for _, s := range dump.Systems {
+		if s != nil {
+			systems = append(systems, s)
+		}
+	}
+
+	if len(systems) == 0 {
+		return nil, ErrNoSystem
+	}
+
+	if len(systems) > 1 {
+		return nil, ErrTooManySystems
+	}
+
+	system := systems[0]
+	device := &common.Device{
+		Common: resourceCommon(&system.Resource, ""),
+		BIOS: &common.BIOS{
+			Common: common.Common{
+				Vendor:   system.Manufacturer,
+				Firmware: firmware(system.BiosVersion),
+			},
+		},
+	}
+	if device.Model == "" {
+		device.Model = system.SKU
+	}
+
+	for _, m := range dump.Managers {
+		if m != nil && m.ManagerType == "BMC" && device.BMC == nil {
+			device.BMC = &common.BMC{
+				Common: resourceCommon(&m.Resource, m.FirmwareVersion),
+				ID:     m.ID,
+			}
+		}
+	}
+
+	for _, c := range dump.Chassis {
+		if c == nil {
+			continue
+		}
+		device.Enclosures = append(device.Enclosures, &common.Enclosure{
+			Common:      resourceCommon(&c.Resource, ""),
+			ID:          c.ID,
+			ChassisType: c.ChassisType,
+		})
+
+		for _, p := range c.PowerSupplies {
+			if p == nil || absent(p.Status) {
+				continue
+			}
+			device.PSUs = append(device.PSUs, &common.PSU{
+				Common:             resourceCommon(&p.Resource, p.FirmwareVersion),
+				ID:                 firstNonEmpty(p.MemberID, p.ID),
+				PowerCapacityWatts: int64(p.PowerCapacityWatts),
+			})
+		}
+	}
+
+	for _, s := range dump.Storage {
+		if s == nil {
+			continue
+		}
+		device.StorageControllers = append(device.StorageControllers, storageControllers(s)...)
+		device.Drives = append(device.Drives, drives(s)...)
+	}
+
+	for _, m := range dump.Memory {
+		if m == nil || absent(m.Status) {
+			continue
+		}
+		device.Memory = append(device.Memory, &common.Memory{
+			Common:       resourceCommon(&m.Resource, m.FirmwareRevision),
+			ID:           m.ID,
+			Slot:         m.DeviceLocator,
+			Type:         m.MemoryDeviceType,
+			FormFactor:   m.BaseModuleType,
+			PartNumber:   m.PartNumber,
+			SizeBytes:    m.CapacityMiB * mebibyte,
+			ClockSpeedHz: m.OperatingSpeedMhz * megahertz,
+		})
+	}
+
+	for _, p := range dump.Processors {
+		if p == nil || absent(p.Status) {
+			continue
+		}
+
+		if strings.EqualFold(p.ProcessorType, "GPU") {
+			device.GPUs = append(device.GPUs, &common.GPU{Common: resourceCommon(&p.Resource, "")})
+			continue
+		}
+
+		device.CPUs = append(device.CPUs, &common.CPU{
+			Common:       resourceCommon(&p.Resource, ""),
+			ID:           p.ID,
+			Slot:         p.Socket,
+			Architecture: p.InstructionSet,
+			ClockSpeedHz: p.MaxSpeedMHz * megahertz,
+			Cores:        p.TotalCores,
+			Threads:      p.TotalThreads,
+		})
+	}
+
+	for _, n := range dump.NetworkAdapters {
+		if n == nil || absent(n.Status) {
+			continue
+		}
+
+		version := ""
+		if len(n.Controllers) > 0 && n.Controllers[0] != nil {
+			version = n.Controllers[0].FirmwarePackageVersion
+		}
+		device.NICs = append(device.NICs, &common.NIC{
+			Common: resourceCommon(&n.Resource, version),
+			ID:     n.ID,
+		})
+	}
+
+	return device, nil
+}
+
+func storageControllers(s *Storage) []*common.StorageController {
+	var out []*common.StorageController
+	for idx, c := range s.StorageControllers {
+		if c == nil || absent(c.Status) {
+			continue
+		}
+		out = append(out, &common.StorageController{
+			Common:                       resourceCommon(&c.Resource, c.FirmwareVersion),
+			ID:                           firstNonEmpty(c.MemberID, c.ID, s.ID+"."+strconv.Itoa(idx)),
+			SupportedControllerProtocols: strings.Join(c.SupportedControllerProtocols, ","),
+			SupportedDeviceProtocols:     strings.Join(c.SupportedDeviceProtocols, ","),
+			SupportedRAIDTypes:           strings.Join(c.SupportedRAIDTypes, ","),
+			SpeedGbps:                    int64(c.SpeedGbps),
+		})
+	}
+	return out
+}
+
+func drives(s *Storage) []*common.Drive {
+	var out []*common.Drive
+	for _, d := range s.Drives {
+		if d == nil || absent(d.Status) {
+			continue
+		}
+		out = append(out, &common.Drive{
+			Common:              resourceCommon(&d.Resource, d.Revision),
+			ID:                  d.ID,
+			Type:                driveType(d.MediaType, d.Protocol),
+			StorageController:   s.ID,
+			Protocol:            d.Protocol,
+			CapacityBytes:       d.CapacityBytes,
+			BlockSizeBytes:      d.BlockSizeBytes,
+			CapableSpeedGbps:    int64(d.CapableSpeedGbs),
+			NegotiatedSpeedGbps: int64(d.NegotiatedSpeedGbs),
+		})
+	}
+	return out
+}
+
+// driveType returns the drive type slug Alloy uses, when there is one.
+func driveType(mediaType, protocol string) string {
+	switch {
+	case mediaType == "SSD" && protocol == "NVMe":
+		return common.SlugDriveTypePCIeNVMEeSSD
+	case mediaType == "SSD" && protocol == "SATA":
+		return common.SlugDriveTypeSATASSD
+	case mediaType == "HDD" && protocol == "SATA":
+		return common.SlugDriveTypeSATAHDD
+	}
+	return mediaType
+}
+
+func resourceCommon(r *Resource, firmwareVersion string) common.Common {
+	c := common.Common{
+		Description: r.Description,
+		Vendor:      r.Manufacturer,
+		Model:       r.Model,
+		Serial:      strings.TrimSpace(r.SerialNumber),
+		ProductName: r.Name,
+		Firmware:    firmware(firmwareVersion),
+	}
+
+	if r.Status != nil {
+		c.Status = &common.Status{State: r.Status.State, Health: r.Status.Health}
+	}
+
+	return c
+}
+
+func firmware(version string) *common.Firmware {
+	if version == "" {
+		return nil
+	}
+	return &common.Firmware{Installed: version}
+}
+
+// absent tells whether a resource stands for an empty slot.
+func absent(s *Status) bool {
+	return s != nil && s.State == stateAbsent
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// biosConfig flattens the BIOS attributes into the string map of rivets.
+func biosConfig(b *Bios) map[string]string {
+	if b == nil || len(b.Attributes) == 0 {
+		return nil
+	}
+
+	cfg := make(map[string]string, len(b.Attributes))
+	for k, v := range b.Attributes {
+		switch value := v.(type) {
+		case nil:
+		case float64:
+			cfg[k] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			cfg[k] = fmt.Sprint(value)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,122 @@
+package redfishconverter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func loadDump(t *testing.T) *Dump {
+	t.Helper()
+	data, err := os.ReadFile("testdata/dump.json")
+	require.NoError(t, err)
+
+	dump := &Dump{}
+	require.NoError(t, json.Unmarshal(data, dump))
+	return dump
+}
+
+func componentsBySlug(srv *rivets.Server) map[string][]*rivets.Component {
+	bySlug := map[string][]*rivets.Component{}
+	for _, c := range srv.Components {
+		bySlug[c.Name] = append(bySlug[c.Name], c)
+	}
+	return bySlug
+}
+
+func TestToRivetsServer(t *testing.T) {
+	t.Parallel()
+	srv, err := ToRivetsServer("server", "facility", loadDump(t))
+	require.NoError(t, err)
+
+	require.Equal(t, "Dell Inc.", srv.Vendor)
+	require.Equal(t, "PowerEdge R6515", srv.Model)
+	require.Equal(t, "ABCD123", srv.Serial)
+	require.Equal(t, "Uefi", srv.BIOSCfg["BootMode"])
+	require.Equal(t, "3200", srv.BIOSCfg["MemFrequency"])
+	require.NotContains(t, srv.BIOSCfg, "PcieEnhancedPreferredIo")
+
+	bySlug := componentsBySlug(srv)
+	require.Equal(t, "2.14.1", bySlug[common.SlugBIOS][0].Firmware.Installed)
+	require.Equal(t, "6.10.30.00", bySlug[common.SlugBMC][0].Firmware.Installed)
+	require.Len(t, bySlug[common.SlugEnclosure], 1)
+
+	// empty slots are left out
+	require.Len(t, bySlug[common.SlugDrive], 1)
+	require.Len(t, bySlug[common.SlugPhysicalMem], 1)
+
+	drive := bySlug[common.SlugDrive][0]
+	require.Equal(t, "203329F89392", drive.Serial)
+	require.Equal(t, "J004", drive.Firmware.Installed)
+	require.Equal(t, common.SlugDriveTypeSATASSD, drive.Attributes.DriveType)
+	require.Equal(t, "AHCI.Embedded.1-1", drive.Attributes.StorageController)
+	require.EqualValues(t, 240057409536, drive.Attributes.CapacityBytes)
+
+	dimm := bySlug[common.SlugPhysicalMem][0]
+	require.EqualValues(t, 32<<30, dimm.Attributes.SizeBytes)
+	require.Equal(t, "A1", dimm.Attributes.Slot)
+
+	cpu := bySlug[common.SlugCPU][0]
+	require.Equal(t, 24, cpu.Attributes.Cores)
+	require.EqualValues(t, 3350*megahertz, cpu.Attributes.ClockSpeedHz)
+
+	psus := bySlug[common.SlugPSU]
+	require.Len(t, psus, 2)
+	require.Equal(t, "Critical", psus[1].Status.Health)
+
+	require.Equal(t, "14.32.10.10", bySlug[common.SlugNIC][0].Firmware.Installed)
+	require.Len(t, bySlug[common.SlugStorageController], 1)
+}
+
+func TestToDeviceSystems(t *testing.T) {
+	t.Parallel()
+	_, err := ToDevice(&Dump{})
+	require.True(t, errors.Is(err, ErrNoSystem))
+
+	_, err = ToDevice(&Dump{Systems: []*System{{}, {}}})
+	require.True(t, errors.Is(err, ErrTooManySystems))
+
+	device, err := ToDevice(&Dump{Systems: []*System{{SKU: "sku"}}})
+	require.NoError(t, err)
+	require.Equal(t, "sku", device.Model)
+	require.Nil(t, device.BMC)
+}
+
+func TestToDeviceNullEntries(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		dump string
+		err  error
+	}{
+		{"null system", `{"Systems": [null]}`, ErrNoSystem},
+		{"null and a system", `{"Systems": [null, {"SKU": "sku"}]}`, nil},
+		{"null managers", `{"Systems": [{}], "Managers": [null]}`, nil},
+		{"null chassis", `{"Systems": [{}], "Chassis": [null, {"PowerSupplies": [null]}]}`, nil},
+		{"null storage", `{"Systems": [{}], "Storage": [null, {"StorageControllers": [null], "Drives": [null]}]}`, nil},
+		{"null memory", `{"Systems": [{}], "Memory": [null]}`, nil},
+		{"null processors", `{"Systems": [{}], "Processors": [null]}`, nil},
+		{"null network adapters", `{"Systems": [{}], "NetworkAdapters": [null, {"Controllers": [null]}]}`, nil},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dump := &Dump{}
+			require.NoError(t, json.Unmarshal([]byte(tc.dump), dump))
+
+			device, err := ToDevice(dump)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, device)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package serverlock
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+// how long releasing a distributed lock may take
+const unlockTimeout = 5 * time.Second
+
+// NATS is a Locker shared by all replicas of the service, backed by a NATS
+// JetStream key-value bucket. A key is held while it exists in the bucket; the
+// bucket's TTL bounds how long a lock survives a replica that died holding it.
+type NATS struct {
+	kv            jetstream.KeyValue
+	retryInterval time.Duration
+	holder        []byte
+	// local serializes the lock holders of this process so that they don't all
+	// poll the bucket
+	local *Local
+}
+
+// NewNATS returns a Locker using the given bucket, polling a held key every
+// retryInterval until it is released.
+func NewNATS(kv jetstream.KeyValue, retryInterval time.Duration) *NATS {
+	holder, _ := os.Hostname()
+	return &NATS{
+		kv:            kv,
+		retryInterval: retryInterval,
+		holder:        []byte(holder),
+		local:         NewLocal(),
+	}
+}
+
+// Lock implements Locker.
+func (n *NATS) Lock(ctx context.Context, key string) (func(), error) {
+	unlockLocal, err := n.local.Lock(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		rev, err := n.kv.Create(ctx, key, n.holder)
+		if err == nil {
+			return func() {
+				ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+				defer cancel()
+
+				// only delete our own lock, it may have expired and been taken since
+				//nolint:errcheck // the bucket TTL releases the lock if this fails
+				n.kv.Delete(ctx, key, jetstream.LastRevision(rev))
+				unlockLocal()
+			}, nil
+		}
+
+		if !errors.Is(err, jetstream.ErrKeyExists) {
+			unlockLocal()
+			return nil, errors.Wrap(err, "acquiring lock "+key)
+		}
+
+		select {
+		case <-ctx.Done():
+			unlockLocal()
+			return nil, ctx.Err()
+		case <-time.After(n.retryInterval):
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package serverlock
+
+import (
+	"context"
+	"sync"
+)
+
+// Locker serializes work on a key, typically a server id.
+type Locker interface {
+	// Lock blocks until the key is held or ctx is done. The returned function
+	// releases the key.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// Local is an in-process Locker.
+type Local struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// NewLocal returns a Locker that serializes work within this process.
+func NewLocal() *Local {
+	return &Local{
+		locks: map[string]*keyLock{},
+	}
+}
+
+// Lock implements Locker.
+func (l *Local) Lock(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	select {
+	case kl.ch <- struct{}{}:
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				<-kl.ch
+				l.release(key, kl)
+			})
+		}, nil
+	case <-ctx.Done():
+		l.release(key, kl)
+		return nil, ctx.Err()
+	}
+}
+
+// release drops a reference to a key lock, forgetting the key once nobody holds
+// or waits for it.
+func (l *Local) release(key string, kl *keyLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kl.refs--
+	if kl.refs == 0 {
+		delete(l.locks, key)
+	}
+}
@@ -0,0 +1,34 @@
+package serverlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	t.Parallel()
+	l := NewLocal()
+
+	unlock, err := l.Lock(context.Background(), "server")
+	require.NoError(t, err)
+
+	// a different key is independent
+	unlockOther, err := l.Lock(context.Background(), "other-server")
+	require.NoError(t, err)
+	unlockOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(ctx, "server")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlock()
+	unlock, err = l.Lock(context.Background(), "server")
+	require.NoError(t, err)
+	unlock()
+
+	require.Empty(t, l.locks)
+}
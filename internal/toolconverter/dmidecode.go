@@ -0,0 +1,194 @@
+package toolconverter
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+)
+
+// The SMBIOS structure types converted from dmidecode output
+const (
+	dmiBIOS        = 0
+	dmiSystem      = 1
+	dmiBaseboard   = 2
+	dmiProcessor   = 4
+	dmiMemory      = 17
+	dmiPowerSupply = 39
+)
+
+// DMIRecord is a structure of the dmidecode text output.
+type DMIRecord struct {
+	Handle string
+	Type   int
+	// Fields are the single line properties, lists are left out
+	Fields map[string]string
+}
+
+// ParseDmidecode splits the text output of dmidecode into its records.
+func ParseDmidecode(data []byte) ([]*DMIRecord, error) {
+	var (
+		records []*DMIRecord
+		current *DMIRecord
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Handle 0x0000, DMI type 0, 26 bytes
+		if strings.HasPrefix(line, "Handle ") {
+			current = &DMIRecord{Fields: map[string]string{}}
+			parts := strings.Split(strings.TrimPrefix(line, "Handle "), ",")
+			current.Handle = strings.TrimSpace(parts[0])
+			if len(parts) > 1 {
+				current.Type, _ = strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(parts[1]), "DMI type "))
+			}
+			records = append(records, current)
+			continue
+		}
+
+		// properties are indented by a tab, list items by two
+		if current == nil || !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "\t"), ":")
+		if !found || strings.TrimSpace(value) == "" {
+			continue
+		}
+		current.Fields[strings.TrimSpace(key)] = clean(value)
+	}
+
+	return records, scanner.Err()
+}
+
+// FromDmidecode converts the text output of dmidecode into the device model of
+// Alloy inventories.
+func FromDmidecode(data []byte) (*common.Device, error) {
+	records, err := ParseDmidecode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	device := &common.Device{}
+	found := false
+	for _, r := range records {
+		f := r.Fields
+		switch r.Type {
+		case dmiBIOS:
+			device.BIOS = &common.BIOS{
+				Common: common.Common{
+					Vendor:   f["Vendor"],
+					Firmware: firmware(f["Version"]),
+				},
+				SizeBytes: dmiBytes(f["ROM Size"]),
+			}
+		case dmiSystem:
+			device.Vendor = f["Manufacturer"]
+			device.Model = f["Product Name"]
+			device.Serial = f["Serial Number"]
+		case dmiBaseboard:
+			device.Mainboard = &common.Mainboard{
+				Common: common.Common{
+					Vendor:      f["Manufacturer"],
+					Model:       f["Product Name"],
+					ProductName: f["Product Name"],
+					Serial:      f["Serial Number"],
+				},
+			}
+		case dmiProcessor:
+			if !strings.HasPrefix(f["Status"], "Populated") {
+				continue
+			}
+			cpu := &common.CPU{
+				Common: common.Common{
+					Vendor: f["Manufacturer"],
+					Model:  f["Version"],
+					Serial: f["Serial Number"],
+				},
+				ID:           r.Handle,
+				Slot:         f["Socket Designation"],
+				ClockSpeedHz: dmiHertz(f["Max Speed"]),
+			}
+			cpu.Cores, _ = strconv.Atoi(f["Core Count"])
+			cpu.Threads, _ = strconv.Atoi(f["Thread Count"])
+			device.CPUs = append(device.CPUs, cpu)
+		case dmiMemory:
+			size := dmiBytes(f["Size"])
+			if size == 0 {
+				continue
+			}
+			device.Memory = append(device.Memory, &common.Memory{
+				Common: common.Common{
+					Vendor: f["Manufacturer"],
+					Model:  f["Part Number"],
+					Serial: f["Serial Number"],
+				},
+				ID:           r.Handle,
+				Slot:         f["Locator"],
+				Type:         f["Type"],
+				FormFactor:   f["Form Factor"],
+				PartNumber:   f["Part Number"],
+				SizeBytes:    size,
+				ClockSpeedHz: dmiHertz(f["Speed"]),
+			})
+		case dmiPowerSupply:
+			if strings.HasPrefix(f["Status"], "Not Present") {
+				continue
+			}
+			device.PSUs = append(device.PSUs, &common.PSU{
+				Common: common.Common{
+					Vendor:   f["Manufacturer"],
+					Model:    f["Model Part Number"],
+					Serial:   f["Serial Number"],
+					Firmware: firmware(f["Revision"]),
+				},
+				ID:                 f["Name"],
+				PowerCapacityWatts: dmiNumber(f["Max Power Capacity"]),
+			})
+		default:
+			continue
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, ErrNoData
+	}
+	return device, nil
+}
+
+// dmiNumber returns the leading number of a value like "550 W".
+func dmiNumber(value string) int64 {
+	number, _, _ := strings.Cut(value, " ")
+	n, _ := strconv.ParseInt(number, 10, 64)
+	return n
+}
+
+// dmiBytes converts a size like "32 GB" into bytes.
+func dmiBytes(value string) int64 {
+	_, unit, _ := strings.Cut(value, " ")
+	multipliers := map[string]int64{
+		"bytes": 1,
+		"kB":    1 << 10,
+		"MB":    1 << 20,
+		"GB":    1 << 30,
+		"TB":    1 << 40,
+	}
+	return dmiNumber(value) * multipliers[unit]
+}
+
+// dmiHertz converts a speed like "3200 MHz" or "3200 MT/s" into hertz.
+func dmiHertz(value string) int64 {
+	_, unit, _ := strings.Cut(value, " ")
+	switch unit {
+	case "MHz", "MT/s":
+		return dmiNumber(value) * 1000 * 1000
+	case "GHz":
+		return dmiNumber(value) * 1000 * 1000 * 1000
+	}
+	return 0
+}
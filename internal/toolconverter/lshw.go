@@ -0,0 +1,200 @@
+package toolconverter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+)
+
+// LshwNode is a node of the hardware tree printed by lshw -json.
+type LshwNode struct {
+	ID            string         `json:"id"`
+	Class         string         `json:"class"`
+	Claimed       bool           `json:"claimed,omitempty"`
+	Disabled      bool           `json:"disabled,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	Product       string         `json:"product,omitempty"`
+	Vendor        string         `json:"vendor,omitempty"`
+	PhysID        string         `json:"physid,omitempty"`
+	BusInfo       string         `json:"businfo,omitempty"`
+	LogicalName   any            `json:"logicalname,omitempty"`
+	Version       string         `json:"version,omitempty"`
+	Serial        string         `json:"serial,omitempty"`
+	Slot          string         `json:"slot,omitempty"`
+	Units         string         `json:"units,omitempty"`
+	Size          int64          `json:"size,omitempty"`
+	Capacity      int64          `json:"capacity,omitempty"`
+	Clock         int64          `json:"clock,omitempty"`
+	Configuration map[string]any `json:"configuration,omitempty"`
+	Children      []*LshwNode    `json:"children,omitempty"`
+}
+
+// config returns a configuration value of the node as a string.
+func (n *LshwNode) config(key string) string {
+	switch v := n.Configuration[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// FromLshw converts the output of lshw -json into the device model of Alloy
+// inventories. Both the object and the single element array lshw versions
+// print are accepted.
+func FromLshw(data []byte) (*common.Device, error) {
+	root := &LshwNode{}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var roots []*LshwNode
+		if err := json.Unmarshal(data, &roots); err != nil {
+			return nil, err
+		}
+		if len(roots) == 0 {
+			return nil, ErrNoData
+		}
+		root = roots[0]
+	} else if err := json.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+
+	// null is valid JSON for any node
+	if root == nil || root.Class != "system" {
+		return nil, ErrNoData
+	}
+
+	device := &common.Device{
+		Common: common.Common{
+			Vendor: clean(root.Vendor),
+			Model:  clean(root.Product),
+			Serial: clean(root.Serial),
+		},
+	}
+	walkLshw(device, root.Children)
+
+	return device, nil
+}
+
+func walkLshw(device *common.Device, nodes []*LshwNode) {
+	for _, n := range nodes {
+		if n == nil || n.Disabled {
+			continue
+		}
+
+		switch {
+		case n.Class == "bus" && n.ID == "core":
+			device.Mainboard = &common.Mainboard{Common: lshwCommon(n), PhysicalID: n.PhysID}
+		case n.Class == "memory" && n.ID == "firmware":
+			device.BIOS = &common.BIOS{Common: lshwCommon(n), SizeBytes: n.Size, CapacityBytes: n.Capacity}
+		case n.Class == "memory" && strings.HasPrefix(n.ID, "bank"):
+			// empty banks have no size
+			if n.Size > 0 {
+				device.Memory = append(device.Memory, &common.Memory{
+					Common:       lshwCommon(n),
+					ID:           n.ID,
+					Slot:         n.Slot,
+					SizeBytes:    n.Size,
+					ClockSpeedHz: n.Clock,
+				})
+			}
+		case n.Class == "processor" && strings.HasPrefix(n.ID, "cpu"):
+			device.CPUs = append(device.CPUs, lshwCPU(n))
+		case n.Class == "network":
+			device.NICs = append(device.NICs, &common.NIC{
+				Common: lshwCommon(n),
+				ID:     n.ID,
+				NICPorts: []*common.NICPort{{
+					ID:         n.ID,
+					BusInfo:    n.BusInfo,
+					PhysicalID: n.PhysID,
+					MacAddress: n.Serial,
+					SpeedBits:  n.Capacity,
+				}},
+			})
+		case n.Class == "storage" && n.config("driver") == "nvme":
+			// NVMe drives are their own controllers, their namespaces are not drives
+			device.Drives = append(device.Drives, lshwNVMe(n))
+			continue
+		case n.Class == "storage":
+			device.StorageControllers = append(device.StorageControllers, &common.StorageController{
+				Common:     lshwCommon(n),
+				ID:         n.ID,
+				BusInfo:    n.BusInfo,
+				PhysicalID: n.PhysID,
+			})
+		case n.Class == "disk" && !strings.HasPrefix(n.ID, "cdrom") && n.Size > 0:
+			device.Drives = append(device.Drives, &common.Drive{
+				Common:        lshwCommon(n),
+				ID:            n.ID,
+				BusInfo:       n.BusInfo,
+				CapacityBytes: n.Size,
+				Protocol:      strings.ToUpper(n.config("ansiversion")),
+			})
+		case n.Class == "display":
+			device.GPUs = append(device.GPUs, &common.GPU{Common: lshwCommon(n)})
+		}
+
+		walkLshw(device, n.Children)
+	}
+}
+
+func lshwCommon(n *LshwNode) common.Common {
+	c := common.Common{
+		Description: clean(n.Description),
+		Vendor:      clean(n.Vendor),
+		Model:       clean(n.Product),
+		ProductName: clean(n.Product),
+		Serial:      clean(n.Serial),
+		Firmware:    firmware(n.Version),
+	}
+
+	if name, ok := n.LogicalName.(string); ok {
+		c.LogicalName = name
+	}
+
+	if fw := n.config("firmware"); fw != "" {
+		c.Firmware = firmware(fw)
+	}
+
+	return c
+}
+
+func lshwCPU(n *LshwNode) *common.CPU {
+	cpu := &common.CPU{
+		Common: lshwCommon(n),
+		ID:     n.ID,
+		Slot:   n.Slot,
+		// lshw reports the current speed as the size, the maximum as the capacity
+		ClockSpeedHz: n.Capacity,
+	}
+	if cpu.ClockSpeedHz == 0 {
+		cpu.ClockSpeedHz = n.Size
+	}
+	// the version lshw reports for CPUs is their family, model and stepping
+	cpu.Firmware = nil
+
+	cpu.Cores, _ = strconv.Atoi(n.config("cores"))
+	cpu.Threads, _ = strconv.Atoi(n.config("threads"))
+	return cpu
+}
+
+func lshwNVMe(n *LshwNode) *common.Drive {
+	drive := &common.Drive{
+		Common:   lshwCommon(n),
+		ID:       n.ID,
+		BusInfo:  n.BusInfo,
+		Type:     common.SlugDriveTypePCIeNVMEeSSD,
+		Protocol: "NVMe",
+	}
+
+	for _, ns := range n.Children {
+		if ns != nil {
+			drive.CapacityBytes += ns.Size
+		}
+	}
+	return drive
+}
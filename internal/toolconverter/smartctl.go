@@ -0,0 +1,150 @@
+package toolconverter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+)
+
+// Smartctl is the output of smartctl --json for a drive, only the properties
+// the inventory uses are decoded.
+type Smartctl struct {
+	Device struct {
+		Name     string `json:"name"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelFamily     string `json:"model_family,omitempty"`
+	ModelName       string `json:"model_name,omitempty"`
+	SCSIVendor      string `json:"scsi_vendor,omitempty"`
+	SCSIProduct     string `json:"scsi_product,omitempty"`
+	SerialNumber    string `json:"serial_number,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	WWN             *struct {
+		NAA int   `json:"naa"`
+		OUI int64 `json:"oui"`
+		ID  int64 `json:"id"`
+	} `json:"wwn,omitempty"`
+	UserCapacity *struct {
+		Bytes int64 `json:"bytes"`
+	} `json:"user_capacity,omitempty"`
+	NVMeTotalCapacity int64 `json:"nvme_total_capacity,omitempty"`
+	LogicalBlockSize  int64 `json:"logical_block_size,omitempty"`
+	// RotationRate is zero for solid state drives
+	RotationRate *int `json:"rotation_rate,omitempty"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status,omitempty"`
+	ATASmartAttributes *struct {
+		Table []struct {
+			Name       string `json:"name"`
+			WhenFailed string `json:"when_failed"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes,omitempty"`
+}
+
+// SMART statuses of drives
+const (
+	smartPassed = "ok"
+	smartFailed = "failed"
+)
+
+// FromSmartctl converts the output of smartctl --json into the device model of
+// Alloy inventories. The output of several drives is accepted as an array.
+func FromSmartctl(data []byte) (*common.Device, error) {
+	var outputs []*Smartctl
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &outputs); err != nil {
+			return nil, err
+		}
+	} else {
+		output := &Smartctl{}
+		if err := json.Unmarshal(data, output); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+
+	device := &common.Device{}
+	for _, o := range outputs {
+		if o == nil || clean(o.SerialNumber) == "" {
+			continue
+		}
+		device.Drives = append(device.Drives, smartctlDrive(o))
+	}
+
+	if len(device.Drives) == 0 {
+		return nil, ErrNoData
+	}
+	return device, nil
+}
+
+func smartctlDrive(o *Smartctl) *common.Drive {
+	drive := &common.Drive{
+		Common: common.Common{
+			Description: clean(o.ModelFamily),
+			Vendor:      clean(o.SCSIVendor),
+			Model:       clean(firstNonEmpty(o.ModelName, o.SCSIProduct)),
+			Serial:      clean(o.SerialNumber),
+			LogicalName: o.Device.Name,
+			Firmware:    firmware(o.FirmwareVersion),
+		},
+		Protocol:       o.Device.Protocol,
+		Type:           smartctlDriveType(o),
+		BlockSizeBytes: o.LogicalBlockSize,
+	}
+
+	drive.CapacityBytes = o.NVMeTotalCapacity
+	if o.UserCapacity != nil && o.UserCapacity.Bytes > 0 {
+		drive.CapacityBytes = o.UserCapacity.Bytes
+	}
+
+	if o.WWN != nil {
+		drive.WWN = fmt.Sprintf("%x%06x%09x", o.WWN.NAA, o.WWN.OUI, o.WWN.ID)
+	}
+
+	if o.SmartStatus != nil {
+		drive.SmartStatus = smartPassed
+		drive.Status = &common.Status{Health: "OK"}
+		if !o.SmartStatus.Passed {
+			drive.SmartStatus = smartFailed
+			drive.Status.Health = "Critical"
+		}
+	}
+
+	// attributes past their threshold
+	if o.ATASmartAttributes != nil {
+		for _, a := range o.ATASmartAttributes.Table {
+			if a.WhenFailed != "" {
+				drive.SmartErrors = append(drive.SmartErrors, a.Name+": "+a.WhenFailed)
+			}
+		}
+	}
+
+	return drive
+}
+
+// smartctlDriveType returns the drive type slug Alloy uses, when it can tell.
+func smartctlDriveType(o *Smartctl) string {
+	switch {
+	case strings.EqualFold(o.Device.Protocol, "NVMe"):
+		return common.SlugDriveTypePCIeNVMEeSSD
+	case o.RotationRate == nil || !strings.EqualFold(o.Device.Protocol, "ATA"):
+		return ""
+	case *o.RotationRate == 0:
+		return common.SlugDriveTypeSATASSD
+	}
+	return common.SlugDriveTypeSATAHDD
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
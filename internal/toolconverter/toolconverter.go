@@ -0,0 +1,54 @@
+package toolconverter
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+)
+
+// ErrNoData is returned for tool output describing no hardware.
+var ErrNoData = errors.New("no hardware in tool output")
+
+// The component slugs each tool reports on. The output of a tool says nothing
+// about components of other slugs.
+var (
+	LshwSlugs = []string{
+		common.SlugBIOS, common.SlugMainboard, common.SlugCPU, common.SlugPhysicalMem,
+		common.SlugNIC, common.SlugDrive, common.SlugStorageController, common.SlugGPU,
+	}
+	DmidecodeSlugs = []string{
+		common.SlugBIOS, common.SlugMainboard, common.SlugCPU, common.SlugPhysicalMem, common.SlugPSU,
+	}
+	// SmartctlSlugs are reported one device at a time, the output of smartctl
+	// says nothing about the other drives of a server.
+	SmartctlSlugs = []string{common.SlugDrive}
+)
+
+// placeholders are values tools report for properties the firmware doesn't fill in.
+var placeholders = map[string]struct{}{
+	"not specified":          {},
+	"not provided":           {},
+	"not available":          {},
+	"to be filled by o.e.m.": {},
+	"default string":         {},
+	"unknown":                {},
+	"none":                   {},
+	"n/a":                    {},
+}
+
+// clean trims a value and drops placeholders.
+func clean(value string) string {
+	value = strings.TrimSpace(value)
+	if _, ok := placeholders[strings.ToLower(value)]; ok {
+		return ""
+	}
+	return value
+}
+
+func firmware(version string) *common.Firmware {
+	if version = clean(version); version == "" {
+		return nil
+	}
+	return &common.Firmware{Installed: version}
+}
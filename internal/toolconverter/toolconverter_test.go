@@ -0,0 +1,152 @@
+package toolconverter
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/stretchr/testify/require"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return data
+}
+
+func TestFromLshw(t *testing.T) {
+	t.Parallel()
+	device, err := FromLshw(readFixture(t, "lshw.json"))
+	require.NoError(t, err)
+
+	require.Equal(t, "Supermicro", device.Vendor)
+	require.Equal(t, "S123456X0001", device.Serial)
+	require.Equal(t, "ZM19BS012345", device.Mainboard.Serial)
+	require.Equal(t, "1.4", device.BIOS.Firmware.Installed)
+
+	// the empty bank is left out
+	require.Len(t, device.Memory, 1)
+	require.EqualValues(t, 16<<30, device.Memory[0].SizeBytes)
+	require.Equal(t, "DIMMA1", device.Memory[0].Slot)
+
+	require.Len(t, device.CPUs, 1)
+	require.Equal(t, 8, device.CPUs[0].Cores)
+	require.Equal(t, 16, device.CPUs[0].Threads)
+	require.EqualValues(t, 5000000000, device.CPUs[0].ClockSpeedHz)
+	require.Nil(t, device.CPUs[0].Firmware)
+
+	require.Len(t, device.NICs, 1)
+	require.Equal(t, "8.30 0x8000a4ae 1.2926.0", device.NICs[0].Firmware.Installed)
+
+	// the NVMe namespace and the DVD reader are not drives
+	require.Len(t, device.Drives, 2)
+	require.Equal(t, "S437NA0M123456", device.Drives[0].Serial)
+	require.Equal(t, common.SlugDriveTypePCIeNVMEeSSD, device.Drives[0].Type)
+	require.EqualValues(t, 960197124096, device.Drives[0].CapacityBytes)
+	require.Equal(t, "1939243E1234", device.Drives[1].Serial)
+
+	require.Len(t, device.StorageControllers, 1)
+
+	// some lshw versions print an array
+	device, err = FromLshw([]byte(`[{"id": "host", "class": "system", "vendor": "Dell Inc."}]`))
+	require.NoError(t, err)
+	require.Equal(t, "Dell Inc.", device.Vendor)
+
+	_, err = FromLshw([]byte(`{"id": "disk", "class": "disk"}`))
+	require.True(t, errors.Is(err, ErrNoData))
+}
+
+func TestFromLshwNullNodes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		data   string
+		drives int
+		err    error
+	}{
+		{"null", `null`, 0, ErrNoData},
+		{"null root", `[null]`, 0, ErrNoData},
+		{"null child", `{"id": "host", "class": "system", "children": [null]}`, 0, nil},
+		{"empty children", `{"id": "host", "class": "system", "children": []}`, 0, nil},
+		{
+			"null grandchild",
+			`{"id": "host", "class": "system", "children": [{"id": "core", "class": "bus", "children": [null, {"id": "disk", "class": "disk", "serial": "d1", "size": 1}]}]}`,
+			1, nil,
+		},
+		{
+			"null namespace",
+			`{"id": "host", "class": "system", "children": [{"id": "nvme0", "class": "storage", "configuration": {"driver": "nvme"}, "children": [null, {"id": "namespace", "class": "disk", "size": 2}]}]}`,
+			1, nil,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			device, err := FromLshw([]byte(tc.data))
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, device.Drives, tc.drives)
+		})
+	}
+}
+
+func TestFromDmidecode(t *testing.T) {
+	t.Parallel()
+	device, err := FromDmidecode(readFixture(t, "dmidecode.txt"))
+	require.NoError(t, err)
+
+	require.Equal(t, "Supermicro", device.Vendor)
+	require.Equal(t, "SYS-5019C-MR", device.Model)
+	require.Equal(t, "1.4", device.BIOS.Firmware.Installed)
+	require.EqualValues(t, 32<<20, device.BIOS.SizeBytes)
+	require.Equal(t, "X11SCM-F", device.Mainboard.Model)
+
+	require.Len(t, device.CPUs, 1)
+	require.Empty(t, device.CPUs[0].Serial, "placeholders are dropped")
+	require.Equal(t, 8, device.CPUs[0].Cores)
+	require.EqualValues(t, 5000*1000*1000, device.CPUs[0].ClockSpeedHz)
+
+	require.Len(t, device.Memory, 1)
+	require.EqualValues(t, 16<<30, device.Memory[0].SizeBytes)
+	require.EqualValues(t, 2666*1000*1000, device.Memory[0].ClockSpeedHz)
+
+	require.Len(t, device.PSUs, 1)
+	require.EqualValues(t, 350, device.PSUs[0].PowerCapacityWatts)
+
+	_, err = FromDmidecode([]byte("# dmidecode 3.3\nNo SMBIOS nor DMI entry point found, sorry.\n"))
+	require.True(t, errors.Is(err, ErrNoData))
+}
+
+func TestFromSmartctl(t *testing.T) {
+	t.Parallel()
+	device, err := FromSmartctl(readFixture(t, "smartctl.json"))
+	require.NoError(t, err)
+
+	// drives smartctl couldn't read are left out
+	require.Len(t, device.Drives, 2)
+
+	sata := device.Drives[0]
+	require.Equal(t, common.SlugDriveTypeSATASSD, sata.Type)
+	require.Equal(t, "D1MU", sata.Firmware.Installed)
+	require.EqualValues(t, 480103981056, sata.CapacityBytes)
+	require.Equal(t, "500a0750b2580e55", sata.WWN)
+	require.Equal(t, smartPassed, sata.SmartStatus)
+	require.Equal(t, []string{"Percent_Lifetime_Remain: now"}, sata.SmartErrors)
+
+	nvme := device.Drives[1]
+	require.Equal(t, common.SlugDriveTypePCIeNVMEeSSD, nvme.Type)
+	require.EqualValues(t, 960197124096, nvme.CapacityBytes)
+	require.Equal(t, smartFailed, nvme.SmartStatus)
+	require.Equal(t, "Critical", nvme.Status.Health)
+
+	// a single drive is an object
+	device, err = FromSmartctl([]byte(`{"device": {"protocol": "ATA"}, "serial_number": "abc", "rotation_rate": 7200}`))
+	require.NoError(t, err)
+	require.Equal(t, common.SlugDriveTypeSATAHDD, device.Drives[0].Type)
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"github.com/metal-toolbox/component-inventory/cmd"
+	_ "github.com/metal-toolbox/component-inventory/cmd/report"
 	_ "github.com/metal-toolbox/component-inventory/cmd/server"
 	_ "github.com/metal-toolbox/component-inventory/cmd/version"
 )
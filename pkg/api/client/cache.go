@@ -0,0 +1,75 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+)
+
+// the number of responses kept for revalidation unless WithResponseCache says otherwise
+const defaultResponseCacheSize = 1024
+
+// cachedResponse is the body of a GET response along with its entity tag.
+type cachedResponse struct {
+	url  string
+	etag string
+	body []byte
+}
+
+// responseCache keeps the most recently used GET responses that carried an ETag,
+// so that they can be revalidated with If-None-Match instead of downloaded again.
+type responseCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// most recently used first
+	order *list.List
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (rc *responseCache) get(url string) *cachedResponse {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	elem, ok := rc.entries[url]
+	if !ok {
+		return nil
+	}
+	rc.order.MoveToFront(elem)
+	return elem.Value.(*cachedResponse)
+}
+
+func (rc *responseCache) put(url, etag string, body []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	cached := &cachedResponse{url: url, etag: etag, body: body}
+	if elem, ok := rc.entries[url]; ok {
+		elem.Value = cached
+		rc.order.MoveToFront(elem)
+		return
+	}
+
+	rc.entries[url] = rc.order.PushFront(cached)
+	for rc.order.Len() > rc.size {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).url)
+	}
+}
+
+func (rc *responseCache) remove(url string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if elem, ok := rc.entries[url]; ok {
+		rc.order.Remove(elem)
+		delete(rc.entries, url)
+	}
+}
@@ -5,8 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
 	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	"github.com/metal-toolbox/component-inventory/pkg/api/overrides"
 
 	"github.com/metal-toolbox/alloy/types"
 	rivets "github.com/metal-toolbox/rivets/types"
@@ -14,12 +20,27 @@ import (
 
 type ServerComponents map[string][]*rivets.Component
 
+// ReportParams selects the servers to include in a report. At least one of
+// Facility or ServerIDs must be set.
+type ReportParams struct {
+	Facility  string
+	ServerIDs []string
+	Inband    bool
+}
+
 // Client can perform queries against the Component Inventory Service.
 type Client interface {
 	Version(context.Context) (string, error)
 	GetServerComponents(context.Context, string, bool) (ServerComponents, error)
+	GetServerHealth(context.Context, string, bool) (*health.ServerHealth, error)
+	GetDegradedReport(context.Context, *ReportParams) (*health.Report, error)
 	UpdateInbandInventory(context.Context, string, *types.InventoryDevice) (string, error)
 	UpdateOutOfbandInventory(context.Context, string, *types.InventoryDevice) (string, error)
+	DeleteServerComponents(context.Context, string, bool) (*history.Entry, error)
+	DeleteServerComponent(ctx context.Context, serverID string, inband bool, slug, serial string) (*history.Entry, error)
+	GetServerHistory(context.Context, string) ([]*history.Entry, error)
+	GetServerOverrides(context.Context, string) (*overrides.Set, error)
+	SetServerOverrides(context.Context, string, *overrides.Set) (*overrides.Set, error)
 }
 
 type cisClient struct {
@@ -30,12 +51,29 @@ type cisClient struct {
 	// Doer for performing requests, typically a *http.Client with any
 	// customized settings, such as certificate chains.
 	client httpRequestDoer
+	// retry is nil when failed requests are not retried
+	retry *retryPolicy
+	// timeout bounds each attempt of a request, zero means no timeout
+	timeout time.Duration
+	// breaker is nil when circuit breaking is disabled
+	breaker *circuitBreaker
+	// generate idempotency keys for POSTs that don't carry one
+	autoIdempotencyKeys bool
+	// cache is nil when GET responses are not cached for revalidation
+	cache *responseCache
+	// the content coding of request bodies and preferred one of responses,
+	// empty when bodies are sent as they are
+	compression string
 }
 
 // Creates a new Client, with reasonable defaults
 func NewClient(serverAddress string, opts ...Option) (Client, error) {
 	// create a client with sane default values
-	client := cisClient{serverAddress: serverAddress}
+	client := cisClient{
+		serverAddress: serverAddress,
+		cache:         newResponseCache(defaultResponseCacheSize),
+		compression:   compression.Gzip,
+	}
 	// mutate client and add all optional params
 	for _, o := range opts {
 		if err := o(&client); err != nil {
@@ -71,6 +109,55 @@ func (c cisClient) GetServerComponents(ctx context.Context, serverID string, inb
 	return sc, err
 }
 
+func (c cisClient) GetServerHealth(ctx context.Context, serverID string, inband bool) (*health.ServerHealth, error) {
+	mode := constants.OutOfBandMode
+	if inband {
+		mode = constants.InBandMode
+	}
+
+	path := fmt.Sprintf("%v/%v%v?mode=%s", constants.ComponentsEndpoint, serverID, constants.ComponentHealthPath, mode)
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	sh := &health.ServerHealth{}
+	if err := json.Unmarshal(resp, sh); err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
+
+func (c cisClient) GetDegradedReport(ctx context.Context, params *ReportParams) (*health.Report, error) {
+	if params == nil || (params.Facility == "" && len(params.ServerIDs) == 0) {
+		return nil, ClientError{Message: "a facility or server list is required"}
+	}
+
+	q := url.Values{}
+	if params.Facility != "" {
+		q.Set("facility", params.Facility)
+	}
+	for _, id := range params.ServerIDs {
+		q.Add("server", id)
+	}
+	q.Set("mode", constants.OutOfBandMode)
+	if params.Inband {
+		q.Set("mode", constants.InBandMode)
+	}
+
+	path := fmt.Sprintf("%v%v?%s", constants.ReportsEndpoint, constants.DegradedReportPath, q.Encode())
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &health.Report{}
+	if err := json.Unmarshal(resp, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
 func (c cisClient) Version(ctx context.Context) (string, error) {
 	resp, err := c.get(ctx, constants.VersionEndpoint)
 	if err != nil {
@@ -109,3 +196,102 @@ func (c cisClient) UpdateOutOfbandInventory(ctx context.Context, serverID string
 
 	return string(resp), nil
 }
+
+// DeleteServerComponents removes all the components of a server from the
+// inventory of a mode, returning the recorded history entry.
+func (c cisClient) DeleteServerComponents(ctx context.Context, serverID string, inband bool) (*history.Entry, error) {
+	mode := constants.OutOfBandMode
+	if inband {
+		mode = constants.InBandMode
+	}
+
+	path := fmt.Sprintf("%v/%v?mode=%s", constants.ComponentsEndpoint, serverID, mode)
+	return c.deleteComponents(ctx, path)
+}
+
+// DeleteServerComponent removes the component of a server with the given slug
+// and serial from the inventory of a mode, returning the recorded history entry.
+func (c cisClient) DeleteServerComponent(ctx context.Context, serverID string, inband bool, slug, serial string) (*history.Entry, error) {
+	mode := constants.OutOfBandMode
+	if inband {
+		mode = constants.InBandMode
+	}
+
+	path := fmt.Sprintf("%v/%v/%v/%v?mode=%s", constants.ComponentsEndpoint, serverID,
+		url.PathEscape(slug), url.PathEscape(serial), mode)
+	return c.deleteComponents(ctx, path)
+}
+
+func (c cisClient) deleteComponents(ctx context.Context, path string) (*history.Entry, error) {
+	resp, err := c.delete(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &history.Entry{}
+	if err := json.Unmarshal(resp, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetServerHistory lists the changes made to the inventory of a server through
+// the API, most recent first.
+func (c cisClient) GetServerHistory(ctx context.Context, serverID string) ([]*history.Entry, error) {
+	path := fmt.Sprintf("%v/%v%v", constants.ComponentsEndpoint, serverID, constants.HistoryPath)
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*history.Entry
+	if err := json.Unmarshal(resp, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetServerOverrides returns the operator-maintained components and annotations
+// of a server.
+func (c cisClient) GetServerOverrides(ctx context.Context, serverID string) (*overrides.Set, error) {
+	path := fmt.Sprintf("%v/%v%v", constants.ComponentsEndpoint, serverID, constants.OverridesPath)
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &overrides.Set{}
+	if err := json.Unmarshal(resp, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// SetServerOverrides replaces the operator-maintained components and annotations
+// of a server, returning them as stored.
+func (c cisClient) SetServerOverrides(ctx context.Context, serverID string, set *overrides.Set) (*overrides.Set, error) {
+	if set == nil {
+		return nil, ClientError{Message: "overrides are required"}
+	}
+
+	if err := set.Validate(); err != nil {
+		return nil, ClientError{Message: err.Error()}
+	}
+
+	body, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse overrides: %v", err)
+	}
+
+	path := fmt.Sprintf("%v/%v%v", constants.ComponentsEndpoint, serverID, constants.OverridesPath)
+	resp, err := c.put(ctx, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &overrides.Set{}
+	if err := json.Unmarshal(resp, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
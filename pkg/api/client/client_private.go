@@ -7,7 +7,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
 	"github.com/pkg/errors"
 )
 
@@ -18,6 +22,13 @@ type httpRequestDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+const (
+	// smaller request bodies aren't worth compressing
+	minCompressedBodyBytes = 1024
+	// bounds what a compressed response may expand to
+	maxDecompressedResponseBytes = 256 << 20
+)
+
 // Option allows setting custom parameters during construction
 type Option func(*cisClient) error
 
@@ -38,58 +49,272 @@ func WithAuthToken(authToken string) Option {
 	}
 }
 
+// WithRetries retries requests that fail with a server error or a transport
+// error up to attempts times in total, waiting an exponentially increasing,
+// jittered delay between minBackoff and maxBackoff. A server asking for a longer
+// delay with Retry-After gets it, up to maxBackoff. Only idempotent requests are
+// retried: GETs, and POSTs sent with an idempotency key.
+func WithRetries(attempts int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *cisClient) error {
+		if attempts < 1 || minBackoff <= 0 || maxBackoff < minBackoff {
+			return ClientError{Message: "retries need at least one attempt and 0 < minBackoff <= maxBackoff"}
+		}
+		c.retry = &retryPolicy{
+			attempts:   attempts,
+			minBackoff: minBackoff,
+			maxBackoff: maxBackoff,
+		}
+		return nil
+	}
+}
+
+// WithTimeout bounds the duration of each attempt of a request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *cisClient) error {
+		if timeout <= 0 {
+			return ClientError{Message: "timeout must be positive"}
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// WithCircuitBreaker fails requests fast with ErrCircuitOpen for the cooldown
+// period once threshold consecutive requests have failed with a server or
+// transport error. After the cooldown a single request is let through, and its
+// outcome decides whether the circuit closes again.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *cisClient) error {
+		if threshold < 1 || cooldown <= 0 {
+			return ClientError{Message: "circuit breaker needs a positive threshold and cooldown"}
+		}
+		c.breaker = newCircuitBreaker(threshold, cooldown)
+		return nil
+	}
+}
+
+// WithIdempotencyKeys makes the client send a generated Idempotency-Key with
+// every POST that doesn't have one set with ContextWithIdempotencyKey. Repeated
+// attempts of a request reuse its key, so with WithRetries inventory submissions
+// are retried without being processed twice.
+func WithIdempotencyKeys() Option {
+	return func(c *cisClient) error {
+		c.autoIdempotencyKeys = true
+		return nil
+	}
+}
+
+// WithResponseCache sets how many GET responses are kept to be revalidated with
+// If-None-Match, so that unchanged resources aren't downloaded again. A size of
+// zero disables caching.
+func WithResponseCache(size int) Option {
+	return func(c *cisClient) error {
+		if size < 0 {
+			return ClientError{Message: "response cache size can't be negative"}
+		}
+		c.cache = nil
+		if size > 0 {
+			c.cache = newResponseCache(size)
+		}
+		return nil
+	}
+}
+
+// WithCompression sets the content coding request bodies are compressed with
+// and responses are asked for in, compression.Gzip by default or
+// compression.Zstd. An empty encoding sends bodies uncompressed, for servers
+// that don't support compression.
+func WithCompression(encoding string) Option {
+	return func(c *cisClient) error {
+		if encoding != "" && !compression.Supported(encoding) {
+			return ClientError{Message: "unsupported compression " + encoding}
+		}
+		c.compression = encoding
+		return nil
+	}
+}
+
 func (c *cisClient) get(ctx context.Context, path string) ([]byte, error) {
+	return c.request(ctx, http.MethodGet, path, nil)
+}
+
+func (c *cisClient) post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	return c.request(ctx, http.MethodPost, path, body)
+}
+
+func (c *cisClient) put(ctx context.Context, path string, body []byte) ([]byte, error) {
+	return c.request(ctx, http.MethodPut, path, body)
+}
+
+func (c *cisClient) delete(ctx context.Context, path string) ([]byte, error) {
+	return c.request(ctx, http.MethodDelete, path, nil)
+}
+
+// request performs a request, retrying it when the client is configured to and
+// the request is safe to repeat.
+func (c *cisClient) request(ctx context.Context, method, path string, body []byte) ([]byte, error) {
 	requestURL, err := url.Parse(fmt.Sprintf("%s%s", c.serverAddress, path))
 	if err != nil {
 		return nil, errors.Wrap(err, "parsing URL")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("error in GET request: %v", err)
+	// keep the same ids across attempts so the server can correlate them
+	reqID := requestIDFromContext(ctx)
+	idempotencyKey := idempotencyKeyFromContext(ctx)
+	if idempotencyKey == "" && method == http.MethodPost && c.autoIdempotencyKeys {
+		idempotencyKey = uuid.NewString()
+	}
+
+	attempts := 1
+	if c.retry != nil && (method == http.MethodGet || idempotencyKey != "") {
+		attempts = c.retry.attempts
+	}
+
+	// compressed once for all attempts
+	encoding := ""
+	if c.compression != "" && len(body) >= minCompressedBodyBytes {
+		compressed, err := compression.Compress(c.compression, body)
+		if err != nil {
+			return nil, errors.Wrap(err, "compressing request body")
+		}
+		body, encoding = compressed, c.compression
 	}
 
-	return c.do(req)
+	for attempt := 1; ; attempt++ {
+		data, err := c.attempt(ctx, method, requestURL.String(), body, encoding, reqID, idempotencyKey)
+		if err == nil || attempt >= attempts || !shouldRetry(ctx, err) {
+			return data, err
+		}
+
+		// don't come back before the server said to, within maxBackoff
+		wait := c.retry.backoff(attempt)
+		var re RequestError
+		if errors.As(err, &re) && re.RetryAfter > wait {
+			wait = min(re.RetryAfter, c.retry.maxBackoff)
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func (c *cisClient) post(ctx context.Context, path string, body []byte) ([]byte, error) {
-	requestURL, err := url.Parse(fmt.Sprintf("%s%s", c.serverAddress, path))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse URL path %v: %v", path, err)
+// attempt sends a request once, with a body in the given content coding.
+func (c *cisClient) attempt(ctx context.Context, method, requestURL string, body []byte, encoding, reqID, idempotencyKey string) ([]byte, error) {
+	trial := false
+	if c.breaker != nil {
+		var ok bool
+		if trial, ok = c.breaker.allow(); !ok {
+			return nil, errors.Wrapf(ErrCircuitOpen, "request_id: %s", reqID)
+		}
+	}
+
+	sent := false
+	defer func() {
+		// a trial request that isn't sent would keep the breaker open for good
+		if c.breaker != nil && !sent {
+			c.breaker.release(trial)
+		}
+	}()
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL.String(), bytes.NewReader(body))
+	var bodyReader io.Reader = http.NoBody
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("error in POST request: %v", err)
+		return nil, fmt.Errorf("error in %s request: %v", method, err)
 	}
 
-	return c.do(req)
+	req.Header.Set(constants.RequestIDHeader, reqID)
+	if encoding != "" {
+		req.Header.Set(constants.ContentEncodingHeader, encoding)
+	}
+	// without compression the transport asks for and decodes gzip by itself
+	if c.compression != "" {
+		req.Header.Set(constants.AcceptEncodingHeader, c.compression)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(constants.IdempotencyKeyHeader, idempotencyKey)
+	}
+	if etag := ifMatchFromContext(ctx); etag != "" {
+		req.Header.Set(constants.IfMatchHeader, etag)
+	}
+
+	var cached *cachedResponse
+	if method == http.MethodGet && c.cache != nil {
+		cached = c.cache.get(req.URL.String())
+	}
+	if cached != nil {
+		req.Header.Set(constants.IfNoneMatchHeader, cached.etag)
+	}
+
+	sent = true
+	data, err := c.do(req, cached)
+	if c.breaker != nil {
+		c.breaker.record(isFailure(err))
+	}
+	return data, err
 }
 
-func (c *cisClient) do(req *http.Request) ([]byte, error) {
+// do sends a request. When a cached response is given and the server reports it
+// as still current, its body is returned.
+func (c *cisClient) do(req *http.Request, cached *cachedResponse) ([]byte, error) {
 	req.Header.Set("Content-Type", "application/json")
 
 	if c.authToken != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("bearer %s", c.authToken))
 	}
 
+	reqID := req.Header.Get(constants.RequestIDHeader)
+
 	response, err := c.client.Do(req)
-	if response == nil {
-		return nil, fmt.Errorf("got empty response body. code: 0, err: %v", err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to send request, request_id: %s", reqID)
 	}
+	defer response.Body.Close()
 
+	// prefer the id the server used, it may have replaced ours
+	if id := response.Header.Get(constants.RequestIDHeader); id != "" {
+		reqID = id
+	}
+
+	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v, code: %v", err, response.StatusCode)
+		return nil, errors.Wrapf(err, "failed to read response body, code: %d, request_id: %s", response.StatusCode, reqID)
+	}
+
+	if encoding := response.Header.Get(constants.ContentEncodingHeader); encoding != "" && encoding != compression.Identity && len(data) > 0 {
+		data, err = compression.Decompress(encoding, bytes.NewReader(data), maxDecompressedResponseBytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress response body, code: %d, request_id: %s", response.StatusCode, reqID)
+		}
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.body, nil
 	}
 
 	if response.StatusCode >= http.StatusMultiStatus {
-		return nil, fmt.Errorf("got bad request. code: %v", response.StatusCode)
+		if cached != nil {
+			c.cache.remove(cached.url)
+		}
+		re := newRequestError(response.StatusCode, reqID, data)
+		re.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+		return nil, re
 	}
-	defer response.Body.Close()
 
-	data, err := io.ReadAll(response.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v, code: %v", err.Error(), response.StatusCode)
+	if req.Method == http.MethodGet && c.cache != nil {
+		if etag := response.Header.Get(constants.ETagHeader); etag != "" {
+			c.cache.put(req.URL.String(), etag, data)
+		}
 	}
 
 	return data, nil
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/metal-toolbox/alloy/types"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		code     int
+		body     string
+		sentinel error
+		message  string
+		detail   string
+	}{
+		{
+			name:     "bad uuid",
+			code:     http.StatusBadRequest,
+			body:     `{"message": "invalid server id", "err": "invalid UUID length: 3", "request_id": "abc"}`,
+			sentinel: ErrValidation,
+			message:  "invalid server id",
+			detail:   "invalid UUID length: 3",
+		},
+		{
+			name:     "not found",
+			code:     http.StatusNotFound,
+			body:     `{"message": "invalid request - route not found"}`,
+			sentinel: ErrNotFound,
+			message:  "invalid request - route not found",
+		},
+		{
+			name:     "unauthorized",
+			code:     http.StatusUnauthorized,
+			body:     `not json`,
+			sentinel: ErrUnauthorized,
+			message:  "not json",
+		},
+		{
+			name:     "stale update",
+			code:     http.StatusPreconditionFailed,
+			body:     `{"message": "components were modified", "err": ""}`,
+			sentinel: ErrPreconditionFailed,
+			message:  "components were modified",
+		},
+		{
+			name:     "throttled",
+			code:     http.StatusTooManyRequests,
+			body:     `{"message": "rate limit exceeded", "err": ""}`,
+			sentinel: ErrRateLimited,
+			message:  "rate limit exceeded",
+		},
+		{
+			name:     "fleetdb outage",
+			code:     http.StatusInternalServerError,
+			body:     `{"message": "components unavailable", "err": "connection refused"}`,
+			sentinel: ErrServer,
+			message:  "components unavailable",
+			detail:   "connection refused",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(constants.RequestIDHeader, r.Header.Get(constants.RequestIDHeader))
+				w.WriteHeader(tc.code)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(srv.URL)
+			require.NoError(t, err)
+
+			ctx := ContextWithRequestID(context.Background(), "my-request")
+			_, err = c.GetServerComponents(ctx, "not-a-uuid", true)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, tc.sentinel))
+
+			var re RequestError
+			require.True(t, errors.As(err, &re))
+			require.Equal(t, tc.code, re.StatusCode)
+			require.Equal(t, tc.message, re.Message)
+			require.Equal(t, tc.detail, re.Err)
+			require.Equal(t, "my-request", re.RequestID)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`"v1.2.3"`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithRetries(3, time.Millisecond, 5*time.Millisecond))
+	require.NoError(t, err)
+
+	got, err := c.Version(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, `"v1.2.3"`, got)
+	require.Equal(t, 3, calls)
+
+	// a POST without an idempotency key is not retried
+	calls = 0
+	_, err = c.UpdateInbandInventory(context.Background(), "server", &types.InventoryDevice{})
+	require.True(t, errors.Is(err, ErrServer))
+	require.Equal(t, 1, calls)
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`"v1.2.3"`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithRetries(2, time.Millisecond, 10*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = c.Version(ctx)
+	require.NoError(t, err, "the retry waits maxBackoff, not an hour")
+	require.Equal(t, 2, calls)
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(constants.RequestIDHeader))
+		if len(received)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`"v1.2.3"`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithRetries(2, time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+
+	// the given id is sent with every attempt
+	_, err = c.Version(ContextWithRequestID(context.Background(), "my-request"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"my-request", "my-request"}, received)
+
+	// without one, an id is generated and kept across attempts
+	received = nil
+	_, err = c.Version(context.Background())
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	require.NotEmpty(t, received[0])
+	require.Equal(t, received[0], received[1])
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithCircuitBreaker(2, time.Hour))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Version(context.Background())
+		require.True(t, errors.Is(err, ErrServer))
+	}
+
+	_, err = c.Version(context.Background())
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	require.Equal(t, 2, calls)
+}
+
+func TestCircuitBreakerTrialNotSent(t *testing.T) {
+	t.Parallel()
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithCircuitBreaker(1, time.Millisecond))
+	require.NoError(t, err)
+	cc := c.(cisClient)
+
+	_, err = cc.attempt(context.Background(), http.MethodGet, srv.URL, nil, "", "id", "")
+	require.True(t, errors.Is(err, ErrServer))
+	time.Sleep(2 * time.Millisecond)
+
+	// the trial request fails before it is sent
+	_, err = cc.attempt(context.Background(), "BAD METHOD", srv.URL, nil, "", "id", "")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrCircuitOpen))
+
+	// another trial is allowed and closes the breaker
+	fail = false
+	_, err = cc.attempt(context.Background(), http.MethodGet, srv.URL, nil, "", "id", "")
+	require.NoError(t, err)
+}
+
+func TestRevalidation(t *testing.T) {
+	t.Parallel()
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(constants.ETagHeader, `"v1"`)
+		if r.Header.Get(constants.IfNoneMatchHeader) == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		_, _ = w.Write([]byte(`{"server_id": "abc", "status": "OK"}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		sh, err := c.GetServerHealth(context.Background(), "abc", true)
+		require.NoError(t, err)
+		require.Equal(t, "abc", sh.ServerID)
+	}
+	require.Equal(t, 1, downloads)
+
+	c, err = NewClient(srv.URL, WithResponseCache(0))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := c.GetServerHealth(context.Background(), "abc", true)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, downloads)
+}
+
+func TestDeleteServerComponent(t *testing.T) {
+	t.Parallel()
+	var method, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()+"?"+r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"action": "delete-component", "mode": "inband", "components": [{"name": "gpu", "serial": "a/b"}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+
+	entry, err := c.DeleteServerComponent(context.Background(), "server", true, "gpu", "a/b")
+	require.NoError(t, err)
+	require.Equal(t, http.MethodDelete, method)
+	require.Equal(t, constants.ComponentsEndpoint+"/server/gpu/a%2Fb?mode=inband", path)
+	require.Equal(t, history.ActionDeleteComponent, entry.Action)
+	require.Len(t, entry.Components, 1)
+	require.Equal(t, "a/b", entry.Components[0].Serial)
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+	var encoding, accept string
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get(constants.ContentEncodingHeader)
+		accept = r.Header.Get(constants.AcceptEncodingHeader)
+
+		received, _ = io.ReadAll(r.Body)
+		if encoding != "" {
+			received, _ = compression.Decompress(encoding, bytes.NewReader(received), 1<<20)
+		}
+
+		body, _ := compression.Compress(compression.Zstd, []byte(`{"message": "ok"}`))
+		w.Header().Set(constants.ContentEncodingHeader, compression.Zstd)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	device := &types.InventoryDevice{Inv: &common.Device{Common: common.Common{Vendor: strings.Repeat("Dell Inc. ", 200)}}}
+
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+	resp, err := c.UpdateInbandInventory(context.Background(), "server", device)
+	require.NoError(t, err)
+	require.Equal(t, `{"message": "ok"}`, resp)
+	require.Equal(t, compression.Gzip, encoding)
+	require.Equal(t, compression.Gzip, accept)
+	require.Contains(t, string(received), "Dell Inc. Dell Inc.")
+
+	// small bodies aren't compressed
+	_, err = c.UpdateInbandInventory(context.Background(), "server", &types.InventoryDevice{})
+	require.NoError(t, err)
+	require.Empty(t, encoding)
+
+	c, err = NewClient(srv.URL, WithCompression(""))
+	require.NoError(t, err)
+	_, err = c.UpdateInbandInventory(context.Background(), "server", device)
+	require.NoError(t, err)
+	require.Empty(t, encoding)
+
+	_, err = NewClient(srv.URL, WithCompression("br"))
+	require.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type (
+	requestIDKey      struct{}
+	idempotencyKeyKey struct{}
+	ifMatchKey        struct{}
+)
+
+// ContextWithRequestID returns a context that makes the client send the given
+// id as the X-Request-ID of its requests. Without it every request is sent
+// with a newly generated id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// ContextWithIdempotencyKey returns a context that makes the client send the
+// given key as the Idempotency-Key of its requests. POSTs are only retried when
+// they carry an idempotency key.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
+
+// ContextWithIfMatch returns a context that makes the client send the given
+// entity tag as the If-Match of its requests. An inventory update carrying the
+// ETag of the components it was based on fails with ErrPreconditionFailed if
+// the components were changed since.
+func ContextWithIfMatch(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, etag)
+}
+
+func ifMatchFromContext(ctx context.Context) string {
+	etag, _ := ctx.Value(ifMatchKey{}).(string)
+	return etag
+}
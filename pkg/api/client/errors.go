@@ -1,6 +1,32 @@
 package client
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors classifying a RequestError by the response status code. Use
+// errors.Is to check for them.
+var (
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is returned when the request was not authenticated or authorized.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrValidation is returned when the server rejected the request as invalid.
+	ErrValidation = errors.New("invalid request")
+	// ErrPreconditionFailed is returned when the resource changed since the
+	// version named with ContextWithIfMatch.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrRateLimited is returned when the client made more requests than the server allows.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServer is returned when the server, or one of its dependencies, failed.
+	ErrServer = errors.New("server failure")
+)
 
 // Error holds the cause of a client error and implements the Error interface.
 type Error struct {
@@ -13,14 +39,88 @@ func (c Error) Error() string {
 }
 
 // RequestError is returned when the client gets an error while performing a request.
+// Retrieve it with errors.As using a RequestError value as the target.
 type RequestError struct {
 	Message    string `json:"message"`
+	Err        string `json:"err,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
 	StatusCode int    `json:"status_code"`
+	// RetryAfter is how long the server asked the client to wait before retrying
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
 }
 
 // Error returns the RequestError in string format
 func (e RequestError) Error() string {
-	return fmt.Sprintf("component-inventory-service client request error, statusCode: %d, message: %s", e.StatusCode, e.Message)
+	msg := fmt.Sprintf("component-inventory-service client request error, statusCode: %d, message: %s", e.StatusCode, e.Message)
+	if e.Err != "" {
+		msg += ", err: " + e.Err
+	}
+	if e.RequestID != "" {
+		msg += ", request_id: " + e.RequestID
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error for the class of failure so that callers
+// can use errors.Is(err, ErrNotFound) and friends.
+func (e RequestError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	case e.StatusCode >= http.StatusBadRequest:
+		return ErrValidation
+	default:
+		return nil
+	}
+}
+
+// newRequestError builds a RequestError from a non-2xx response body. Bodies that
+// aren't the service's JSON error shape are kept verbatim as the message.
+func newRequestError(statusCode int, requestID string, body []byte) RequestError {
+	re := RequestError{
+		StatusCode: statusCode,
+		RequestID:  requestID,
+	}
+
+	var payload struct {
+		Message   string `json:"message"`
+		Err       string `json:"err"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		re.Message = string(body)
+		return re
+	}
+
+	re.Message = payload.Message
+	re.Err = payload.Err
+	if re.Err == "" {
+		re.Err = payload.Error
+	}
+	if re.RequestID == "" {
+		re.RequestID = payload.RequestID
+	}
+
+	return re
+}
+
+// parseRetryAfter returns the delay of a Retry-After header given in seconds.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 // ClientError is returned when invalid arguments are provided to the client
@@ -29,3 +129,8 @@ func (e RequestError) Error() string {
 type ClientError struct {
 	Message string
 }
+
+// Error returns the ClientError in string format
+func (e ClientError) Error() string {
+	return "component-inventory-service client invalid argument - " + e.Message
+}
@@ -13,8 +13,11 @@ import (
 	context "context"
 	reflect "reflect"
 
-	common "github.com/bmc-toolbox/common"
+	types "github.com/metal-toolbox/alloy/types"
 	client "github.com/metal-toolbox/component-inventory/pkg/api/client"
+	health "github.com/metal-toolbox/component-inventory/pkg/api/health"
+	history "github.com/metal-toolbox/component-inventory/pkg/api/history"
+	overrides "github.com/metal-toolbox/component-inventory/pkg/api/overrides"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,23 +44,128 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// DeleteServerComponent mocks base method.
+func (m *MockClient) DeleteServerComponent(ctx context.Context, serverID string, inband bool, slug, serial string) (*history.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteServerComponent", ctx, serverID, inband, slug, serial)
+	ret0, _ := ret[0].(*history.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteServerComponent indicates an expected call of DeleteServerComponent.
+func (mr *MockClientMockRecorder) DeleteServerComponent(ctx, serverID, inband, slug, serial any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteServerComponent", reflect.TypeOf((*MockClient)(nil).DeleteServerComponent), ctx, serverID, inband, slug, serial)
+}
+
+// DeleteServerComponents mocks base method.
+func (m *MockClient) DeleteServerComponents(arg0 context.Context, arg1 string, arg2 bool) (*history.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteServerComponents", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*history.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteServerComponents indicates an expected call of DeleteServerComponents.
+func (mr *MockClientMockRecorder) DeleteServerComponents(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteServerComponents", reflect.TypeOf((*MockClient)(nil).DeleteServerComponents), arg0, arg1, arg2)
+}
+
+// GetDegradedReport mocks base method.
+func (m *MockClient) GetDegradedReport(arg0 context.Context, arg1 *client.ReportParams) (*health.Report, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDegradedReport", arg0, arg1)
+	ret0, _ := ret[0].(*health.Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDegradedReport indicates an expected call of GetDegradedReport.
+func (mr *MockClientMockRecorder) GetDegradedReport(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDegradedReport", reflect.TypeOf((*MockClient)(nil).GetDegradedReport), arg0, arg1)
+}
+
 // GetServerComponents mocks base method.
-func (m *MockClient) GetServerComponents(arg0 context.Context, arg1 string) (client.ServerComponents, error) {
+func (m *MockClient) GetServerComponents(arg0 context.Context, arg1 string, arg2 bool) (client.ServerComponents, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetServerComponents", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetServerComponents", arg0, arg1, arg2)
 	ret0, _ := ret[0].(client.ServerComponents)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetServerComponents indicates an expected call of GetServerComponents.
-func (mr *MockClientMockRecorder) GetServerComponents(arg0, arg1 any) *gomock.Call {
+func (mr *MockClientMockRecorder) GetServerComponents(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerComponents", reflect.TypeOf((*MockClient)(nil).GetServerComponents), arg0, arg1, arg2)
+}
+
+// GetServerHealth mocks base method.
+func (m *MockClient) GetServerHealth(arg0 context.Context, arg1 string, arg2 bool) (*health.ServerHealth, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServerHealth", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*health.ServerHealth)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServerHealth indicates an expected call of GetServerHealth.
+func (mr *MockClientMockRecorder) GetServerHealth(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerHealth", reflect.TypeOf((*MockClient)(nil).GetServerHealth), arg0, arg1, arg2)
+}
+
+// GetServerHistory mocks base method.
+func (m *MockClient) GetServerHistory(arg0 context.Context, arg1 string) ([]*history.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServerHistory", arg0, arg1)
+	ret0, _ := ret[0].([]*history.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServerHistory indicates an expected call of GetServerHistory.
+func (mr *MockClientMockRecorder) GetServerHistory(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerHistory", reflect.TypeOf((*MockClient)(nil).GetServerHistory), arg0, arg1)
+}
+
+// GetServerOverrides mocks base method.
+func (m *MockClient) GetServerOverrides(arg0 context.Context, arg1 string) (*overrides.Set, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServerOverrides", arg0, arg1)
+	ret0, _ := ret[0].(*overrides.Set)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServerOverrides indicates an expected call of GetServerOverrides.
+func (mr *MockClientMockRecorder) GetServerOverrides(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerOverrides", reflect.TypeOf((*MockClient)(nil).GetServerOverrides), arg0, arg1)
+}
+
+// SetServerOverrides mocks base method.
+func (m *MockClient) SetServerOverrides(arg0 context.Context, arg1 string, arg2 *overrides.Set) (*overrides.Set, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetServerOverrides", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*overrides.Set)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetServerOverrides indicates an expected call of SetServerOverrides.
+func (mr *MockClientMockRecorder) SetServerOverrides(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServerComponents", reflect.TypeOf((*MockClient)(nil).GetServerComponents), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetServerOverrides", reflect.TypeOf((*MockClient)(nil).SetServerOverrides), arg0, arg1, arg2)
 }
 
 // UpdateInbandInventory mocks base method.
-func (m *MockClient) UpdateInbandInventory(arg0 context.Context, arg1 string, arg2 *common.Device) (string, error) {
+func (m *MockClient) UpdateInbandInventory(arg0 context.Context, arg1 string, arg2 *types.InventoryDevice) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateInbandInventory", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
@@ -72,7 +180,7 @@ func (mr *MockClientMockRecorder) UpdateInbandInventory(arg0, arg1, arg2 any) *g
 }
 
 // UpdateOutOfbandInventory mocks base method.
-func (m *MockClient) UpdateOutOfbandInventory(arg0 context.Context, arg1 string, arg2 *common.Device) (string, error) {
+func (m *MockClient) UpdateOutOfbandInventory(arg0 context.Context, arg1 string, arg2 *types.InventoryDevice) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateOutOfbandInventory", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned without contacting the server while the circuit
+// breaker is open after repeated failures.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// retryPolicy controls how failed requests are retried.
+type retryPolicy struct {
+	attempts   int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// backoff returns the delay before the given retry (starting at 1) using
+// exponential backoff with full jitter, so that many clients failing at the
+// same time don't retry in lockstep.
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	d := p.minBackoff << (retry - 1)
+	if d <= 0 || d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	//nolint:gosec // jitter doesn't need a cryptographic source
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+// shouldRetry reports whether a failed attempt may succeed if repeated. Server
+// failures and transport errors are retried; the server rejecting the request
+// is not, nor is the caller's context expiring.
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var re RequestError
+	if errors.As(err, &re) {
+		return errors.Is(re, ErrServer) || errors.Is(re, ErrRateLimited)
+	}
+
+	return true
+}
+
+// isFailure reports whether an attempt counts against the circuit breaker.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var re RequestError
+	if errors.As(err, &re) {
+		return errors.Is(re, ErrServer)
+	}
+
+	return true
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// circuitBreaker stops requests to the server for a cooldown period after a
+// number of consecutive failures, then lets a single trial request through to
+// find out whether the server has recovered.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a request may be sent now, and whether it is the
+// trial request of an open breaker. A request allowed through is followed by
+// record once sent, or by release if it isn't.
+func (cb *circuitBreaker) allow() (trial, ok bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < cb.threshold {
+		return false, true
+	}
+
+	// open: wait out the cooldown, then allow one trial request at a time
+	if time.Now().Before(cb.openUntil) || cb.trial {
+		return false, false
+	}
+
+	cb.trial = true
+	return true, true
+}
+
+// release ends a request allowed through that wasn't sent, so there is no
+// outcome to record. A trial request makes way for another.
+func (cb *circuitBreaker) release(trial bool) {
+	if !trial {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+}
+
+// record updates the breaker with the outcome of a request.
+func (cb *circuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+	if !failed {
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.openUntil = time.Now().Add(cb.cooldown)
+	}
+}
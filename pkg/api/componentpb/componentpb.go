@@ -0,0 +1,256 @@
+package componentpb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	rivets "github.com/metal-toolbox/rivets/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrMalformed is returned for data that isn't an encoding of components.proto.
+var ErrMalformed = errors.New("malformed components message")
+
+// Components are the components of a server, as in components.proto.
+type Components struct {
+	ServerID   string
+	Components []*Component
+}
+
+// Component is a component, as in components.proto.
+type Component struct {
+	Slug       string
+	Vendor     string
+	Model      string
+	Serial     string
+	Firmware   string
+	State      string
+	Health     string
+	Updated    time.Time
+	Attributes map[string]string
+}
+
+// FromRivets converts the components of a server.
+func FromRivets(serverID string, components []*rivets.Component) *Components {
+	msg := &Components{ServerID: serverID, Components: make([]*Component, 0, len(components))}
+	for _, c := range components {
+		pc := &Component{
+			Slug:       c.Name,
+			Vendor:     c.Vendor,
+			Model:      c.Model,
+			Serial:     c.Serial,
+			Updated:    c.UpdatedAt,
+			Attributes: FlattenAttributes(c.Attributes),
+		}
+		if c.Firmware != nil {
+			pc.Firmware = c.Firmware.Installed
+		}
+		if c.Status != nil {
+			pc.State = c.Status.State
+			pc.Health = c.Status.Health
+		}
+		msg.Components = append(msg.Components, pc)
+	}
+	return msg
+}
+
+// FlattenAttributes returns the attributes with a value by their JSON key.
+// Metadata entries are keyed "metadata.<key>", lists of strings are comma
+// separated and other lists are left out.
+func FlattenAttributes(attrs *rivets.ComponentAttributes) map[string]string {
+	if attrs == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil
+	}
+
+	// numbers as written, sizes in bytes don't all fit a float64
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		return nil
+	}
+
+	flat := make(map[string]string, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			flat[key] = v
+		case json.Number:
+			flat[key] = v.String()
+		case bool:
+			flat[key] = strconv.FormatBool(v)
+		case map[string]any:
+			for mk, mv := range v {
+				if s, ok := mv.(string); ok {
+					flat[key+"."+mk] = s
+				}
+			}
+		case []any:
+			var items []string
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					items = append(items, s)
+				}
+			}
+			if len(items) > 0 {
+				flat[key] = strings.Join(items, ",")
+			}
+		}
+	}
+	return flat
+}
+
+// Marshal encodes the components.
+func (m *Components) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.ServerID)
+	for _, c := range m.Components {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, c.marshal())
+	}
+	return b
+}
+
+func (c *Component) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, c.Slug)
+	b = appendString(b, 2, c.Vendor)
+	b = appendString(b, 3, c.Model)
+	b = appendString(b, 4, c.Serial)
+	b = appendString(b, 5, c.Firmware)
+	b = appendString(b, 6, c.State)
+	b = appendString(b, 7, c.Health)
+	if !c.Updated.IsZero() {
+		b = protowire.AppendTag(b, 8, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(c.Updated.Unix()))
+	}
+
+	// sorted, so the same components always encode the same
+	keys := make([]string, 0, len(c.Attributes))
+	for k := range c.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, c.Attributes[k])
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// appendString appends a string field, proto3 leaves out empty ones.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// Unmarshal decodes components, skipping unknown fields.
+func Unmarshal(data []byte) (*Components, error) {
+	m := &Components{}
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			m.ServerID = string(value)
+		case num == 2 && typ == protowire.BytesType:
+			c, err := unmarshalComponent(value)
+			if err != nil {
+				return err
+			}
+			m.Components = append(m.Components, c)
+		}
+		return nil
+	})
+	return m, err
+}
+
+func unmarshalComponent(data []byte) (*Component, error) {
+	c := &Component{}
+	strs := map[protowire.Number]*string{
+		1: &c.Slug, 2: &c.Vendor, 3: &c.Model, 4: &c.Serial,
+		5: &c.Firmware, 6: &c.State, 7: &c.Health,
+	}
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if s, ok := strs[num]; ok && typ == protowire.BytesType {
+			*s = string(value)
+			return nil
+		}
+
+		switch {
+		case num == 8 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return ErrMalformed
+			}
+			c.Updated = time.Unix(int64(v), 0).UTC()
+		case num == 9 && typ == protowire.BytesType:
+			var key, val string
+			err := consumeFields(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					key = string(value)
+				case num == 2 && typ == protowire.BytesType:
+					val = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if c.Attributes == nil {
+				c.Attributes = map[string]string{}
+			}
+			c.Attributes[key] = val
+		}
+		return nil
+	})
+	return c, err
+}
+
+// consumeFields calls fn with each field of a message. The value of length
+// delimited fields is their content, that of other fields their encoding.
+func consumeFields(data []byte, fn func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+
+		var value []byte
+		if typ == protowire.BytesType {
+			value, n = protowire.ConsumeBytes(data)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n >= 0 {
+				value = data[:n]
+			}
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
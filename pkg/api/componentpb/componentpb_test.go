@@ -0,0 +1,198 @@
+package componentpb
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	components := []*rivets.Component{
+		{
+			Name:      common.SlugDrive,
+			Vendor:    "Micron",
+			Model:     "MTFDDAK480TDS",
+			Serial:    "2107303C1A2B",
+			UpdatedAt: updated,
+			Firmware:  &common.Firmware{Installed: "D3MU001"},
+			Status:    &common.Status{State: "Enabled", Health: "OK"},
+			Attributes: &rivets.ComponentAttributes{
+				CapacityBytes: 480103981056,
+				Protocol:      "SATA",
+				SmartErrors:   []string{"crc", "realloc"},
+				Metadata:      map[string]string{"bay": "3"},
+			},
+		},
+		{Name: common.SlugBIOS},
+	}
+
+	msg := FromRivets("9e2a6d0c-0d2e-4b7a-8c65-3e6c1f6f0a11", components)
+	data := msg.Marshal()
+	require.Equal(t, data, msg.Marshal(), "encoding is deterministic")
+
+	got, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, "9e2a6d0c-0d2e-4b7a-8c65-3e6c1f6f0a11", got.ServerID)
+	require.Len(t, got.Components, 2)
+
+	drive := got.Components[0]
+	require.Equal(t, "D3MU001", drive.Firmware)
+	require.Equal(t, "OK", drive.Health)
+	require.Equal(t, updated, drive.Updated)
+	require.Equal(t, map[string]string{
+		"capacity_bytes": "480103981056",
+		"protocol":       "SATA",
+		"smart_errors":   "crc,realloc",
+		"metadata.bay":   "3",
+	}, drive.Attributes)
+
+	require.Equal(t, &Component{Slug: common.SlugBIOS}, got.Components[1])
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	// fields this version doesn't know are skipped
+	data := protowire.AppendTag(nil, 15, protowire.VarintType)
+	data = protowire.AppendVarint(data, 42)
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, "server")
+
+	got, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, "server", got.ServerID)
+
+	_, err = Unmarshal(data[:len(data)-1])
+	require.ErrorIs(t, err, ErrMalformed)
+}
+
+// schemaFields matches the field declarations of components.proto.
+var schemaFields = regexp.MustCompile(`^(repeated )?(map<string, string>|\w+) (\w+) = (\d+);$`)
+
+// loadSchema builds the descriptor of components.proto from the file itself,
+// which only declares messages of scalar, message and map fields.
+func loadSchema(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	data, err := os.ReadFile("components.proto")
+	require.NoError(t, err)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("components.proto"),
+		Syntax: proto.String("proto3"),
+	}
+	var msg *descriptorpb.DescriptorProto
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "package "):
+			file.Package = proto.String(strings.TrimSuffix(strings.TrimPrefix(line, "package "), ";"))
+		case strings.HasPrefix(line, "message "):
+			msg = &descriptorpb.DescriptorProto{Name: proto.String(strings.Fields(line)[1])}
+			file.MessageType = append(file.MessageType, msg)
+		case schemaFields.MatchString(line):
+			m := schemaFields.FindStringSubmatch(line)
+			number, err := strconv.Atoi(m[4])
+			require.NoError(t, err)
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(m[3]),
+				JsonName: proto.String(m[3]),
+				Number:   proto.Int32(int32(number)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if m[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+
+			switch m[2] {
+			case "string":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+			case "int64":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+			case "map<string, string>":
+				entry := "AttributesEntry"
+				msg.NestedType = append(msg.NestedType, &descriptorpb.DescriptorProto{
+					Name: proto.String(entry),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						{Name: proto.String("key"), JsonName: proto.String("key"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+						{Name: proto.String("value"), JsonName: proto.String("value"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				})
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + file.GetPackage() + "." + msg.GetName() + "." + entry)
+			default:
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + file.GetPackage() + "." + m[2])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	require.NoError(t, err)
+	return fd
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	file := loadSchema(t)
+	schema := file.Messages().ByName("Components")
+	require.NotNil(t, schema)
+	require.Equal(t, 9, file.Messages().ByName("Component").Fields().Len())
+
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data := FromRivets("server", []*rivets.Component{{
+		Name:      common.SlugDrive,
+		Serial:    "d1",
+		UpdatedAt: updated,
+		Firmware:  &common.Firmware{Installed: "1.0"},
+		Status:    &common.Status{State: "Enabled", Health: "OK"},
+		Attributes: &rivets.ComponentAttributes{
+			SmartErrors: []string{"crc", "realloc"},
+			Metadata:    map[string]string{"bay": "3"},
+		},
+	}}).Marshal()
+
+	msg := dynamicpb.NewMessage(schema)
+	require.NoError(t, proto.Unmarshal(data, msg))
+	require.Empty(t, msg.GetUnknown(), "every field is in the schema")
+	require.Equal(t, "server", msg.Get(schema.Fields().ByName("server_id")).String())
+
+	components := msg.Get(schema.Fields().ByName("components")).List()
+	require.Equal(t, 1, components.Len())
+	c := components.Get(0).Message()
+	require.Empty(t, c.GetUnknown())
+
+	fields := c.Descriptor().Fields()
+	require.Equal(t, common.SlugDrive, c.Get(fields.ByName("slug")).String())
+	require.Equal(t, "d1", c.Get(fields.ByName("serial")).String())
+	require.Equal(t, "1.0", c.Get(fields.ByName("firmware")).String())
+	require.Equal(t, "Enabled", c.Get(fields.ByName("state")).String())
+	require.Equal(t, "OK", c.Get(fields.ByName("health")).String())
+	require.Equal(t, updated.Unix(), c.Get(fields.ByName("updated")).Int())
+
+	attributes := map[string]string{}
+	c.Get(fields.ByName("attributes")).Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		attributes[k.String()] = v.String()
+		return true
+	})
+	require.Equal(t, map[string]string{"smart_errors": "crc,realloc", "metadata.bay": "3"}, attributes)
+}
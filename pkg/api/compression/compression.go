@@ -0,0 +1,167 @@
+// Package compression encodes and decodes the gzip and zstd content codings
+// used for request and response bodies.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, as in Content-Encoding and Accept-Encoding headers
+const (
+	Gzip     = "gzip"
+	Zstd     = "zstd"
+	Identity = "identity"
+)
+
+var (
+	// ErrUnsupported is returned for content codings other than gzip and zstd.
+	ErrUnsupported = errors.New("unsupported content encoding")
+	// ErrTooLarge is returned when a body decompresses to more than allowed.
+	ErrTooLarge = errors.New("decompressed body too large")
+)
+
+// Supported reports whether the content coding is one this package handles.
+func Supported(encoding string) bool {
+	return encoding == Gzip || encoding == Zstd
+}
+
+// Compress encodes data with the content coding.
+func Compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decodes a body in the content coding, failing with ErrTooLarge
+// rather than decoding more than maxBytes.
+func Decompress(encoding string, r io.Reader, maxBytes int64) ([]byte, error) {
+	var decoded io.Reader
+	switch encoding {
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		decoded = zr
+	case Zstd:
+		zr, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(maxBytes)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		decoded = zr
+	default:
+		return nil, ErrUnsupported
+	}
+
+	data, err := io.ReadAll(io.LimitReader(decoded, maxBytes+1))
+	switch {
+	case errors.Is(err, zstd.ErrDecoderSizeExceeded), errors.Is(err, zstd.ErrWindowSizeExceeded):
+		return nil, ErrTooLarge
+	case err != nil:
+		return nil, err
+	case int64(len(data)) > maxBytes:
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Writer compresses what is written to it. Close must be called to write out
+// the end of the encoding, after which the Writer can't be used anymore.
+type Writer interface {
+	io.WriteCloser
+	// Flush writes out what was compressed so far.
+	Flush() error
+}
+
+// encoders are expensive to set up, zstd ones in particular
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdWriters = sync.Pool{New: func() any {
+		// only fails on invalid options
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// NewWriter returns a Writer compressing into w with the content coding.
+func NewWriter(encoding string, w io.Writer) (Writer, error) {
+	switch encoding {
+	case Gzip:
+		zw := gzipWriters.Get().(*gzip.Writer)
+		zw.Reset(w)
+		return &pooledWriter{Writer: zw, pool: &gzipWriters}, nil
+	case Zstd:
+		zw := zstdWriters.Get().(*zstd.Encoder)
+		zw.Reset(w)
+		return &pooledWriter{Writer: zw, pool: &zstdWriters}, nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// pooledWriter returns its encoder to the pool when closed.
+type pooledWriter struct {
+	Writer
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.Writer.Close()
+	w.pool.Put(w.Writer)
+	w.Writer = nil
+	return err
+}
+
+// Negotiate picks the content coding to respond with from an Accept-Encoding
+// header, preferring zstd when the client weighs both the same. It returns ""
+// when the response is better left uncompressed.
+func Negotiate(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "*" {
+			coding = Gzip
+		}
+		if !Supported(coding) {
+			continue
+		}
+
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if q > bestQ || (q == bestQ && q > 0 && coding == Zstd) {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,61 @@
+package compression
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	data := []byte(strings.Repeat(`{"name": "Drive", "vendor": "Micron"}`, 100))
+
+	for _, encoding := range []string{Gzip, Zstd} {
+		encoding := encoding
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+			compressed, err := Compress(encoding, data)
+			require.NoError(t, err)
+			require.Less(t, len(compressed), len(data))
+
+			got, err := Decompress(encoding, bytes.NewReader(compressed), int64(len(data)))
+			require.NoError(t, err)
+			require.Equal(t, data, got)
+
+			_, err = Decompress(encoding, bytes.NewReader(compressed), int64(len(data)-1))
+			require.ErrorIs(t, err, ErrTooLarge)
+
+			_, err = Decompress(encoding, bytes.NewReader(data), int64(len(data)))
+			require.Error(t, err)
+		})
+	}
+
+	_, err := Compress("br", data)
+	require.ErrorIs(t, err, ErrUnsupported)
+	_, err = Decompress("br", bytes.NewReader(data), 1)
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", Gzip},
+		{"gzip, deflate, br", Gzip},
+		{"gzip, zstd", Zstd},
+		{"zstd;q=0.5, gzip", Gzip},
+		{"gzip;q=0, zstd;q=0", ""},
+		{"*", Gzip},
+		{"GZIP; q=0.8", Gzip},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.want, Negotiate(tc.header), tc.header)
+	}
+}
@@ -1,10 +1,50 @@
 package constants
 
 const (
-	LivenessEndpoint   = "/_health/liveness"
-	VersionEndpoint    = "/api/version"
-	ComponentsEndpoint = "/components"
-	InventoryEndpoint  = "/inventory"
-	OutOfBandMode      = "outofband"
-	InBandMode         = "inband"
+	LivenessEndpoint     = "/_health/liveness"
+	ReadinessEndpoint    = "/_health/readiness"
+	VersionEndpoint      = "/api/version"
+	RoutesEndpoint       = "/api/routes"
+	OpenAPIEndpoint      = "/api/openapi.json"
+	DiagnosticsEndpoint  = "/api/diagnostics"
+	ComponentsEndpoint   = "/components"
+	InventoryEndpoint    = "/inventory"
+	ComponentHealthPath  = "/health"
+	HistoryPath          = "/history"
+	OverridesPath        = "/overrides"
+	ReportsEndpoint      = "/reports"
+	DegradedReportPath   = "/degraded"
+	OutOfBandMode        = "outofband"
+	InBandMode           = "inband"
+	RequestIDHeader      = "X-Request-ID"
+	IdempotencyKeyHeader = "Idempotency-Key"
+	ETagHeader           = "ETag"
+	IfMatchHeader        = "If-Match"
+	IfNoneMatchHeader    = "If-None-Match"
+	LastModifiedHeader   = "Last-Modified"
+	// ContentEncodingHeader and AcceptEncodingHeader carry gzip or zstd
+	ContentEncodingHeader = "Content-Encoding"
+	AcceptEncodingHeader  = "Accept-Encoding"
+)
+
+// Content types of the inventory formats accepted besides Alloy's JSON
+const (
+	// RedfishContentType is a dump of the Redfish resources of a server
+	RedfishContentType = "application/vnd.redfish+json"
+	// LshwContentType is the output of lshw -json
+	LshwContentType = "application/vnd.lshw+json"
+	// DmidecodeContentType is the text output of dmidecode
+	DmidecodeContentType = "text/vnd.dmidecode"
+	// SmartctlContentType is the output of smartctl --json, an array for several drives
+	SmartctlContentType = "application/vnd.smartctl+json"
+)
+
+// Content types the components of a server are served in besides JSON
+const (
+	// CSVContentType is one row per component with its key attributes
+	CSVContentType = "text/csv"
+	// YAMLContentType is the JSON document as YAML
+	YAMLContentType = "application/yaml"
+	// ProtobufContentType is the Components message of componentpb/components.proto
+	ProtobufContentType = "application/x-protobuf"
 )
@@ -0,0 +1,237 @@
+package health
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// Status is the rolled-up health of a component, a component type or a server.
+type Status string
+
+const (
+	StatusOK       Status = "OK"
+	StatusWarning  Status = "Warning"
+	StatusCritical Status = "Critical"
+	// StatusUnknown is used for components that do not report a health. Unknown
+	// components do not degrade the health of their slug or server.
+	StatusUnknown Status = "Unknown"
+
+	// stateAbsent is the redfish state of an empty slot
+	stateAbsent = "absent"
+)
+
+// severity orders statuses so that the worst one wins a rollup
+var severity = map[Status]int{
+	StatusUnknown:  0,
+	StatusOK:       1,
+	StatusWarning:  2,
+	StatusCritical: 3,
+}
+
+// Worse returns the more severe of two statuses.
+func Worse(a, b Status) Status {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// ComponentHealth describes a single component that is not healthy.
+type ComponentHealth struct {
+	Slug    string   `json:"slug"`
+	Serial  string   `json:"serial"`
+	Slot    string   `json:"slot,omitempty"`
+	Vendor  string   `json:"vendor,omitempty"`
+	Model   string   `json:"model,omitempty"`
+	Status  Status   `json:"status"`
+	Health  string   `json:"health,omitempty"`
+	State   string   `json:"state,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// SlugHealth is the rolled-up health of all components of a given type.
+type SlugHealth struct {
+	Status   Status             `json:"status"`
+	Count    int                `json:"count"`
+	Degraded []*ComponentHealth `json:"degraded,omitempty"`
+}
+
+// ServerHealth is the rolled-up health of all the components of a server.
+type ServerHealth struct {
+	ServerID string                 `json:"server_id"`
+	Status   Status                 `json:"status"`
+	Slugs    map[string]*SlugHealth `json:"slugs"`
+}
+
+// Degraded returns every component of the server in a non-OK state, ordered by
+// slug and serial.
+func (sh *ServerHealth) Degraded() []*ComponentHealth {
+	slugs := make([]string, 0, len(sh.Slugs))
+	for slug := range sh.Slugs {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	var degraded []*ComponentHealth
+	for _, slug := range slugs {
+		degraded = append(degraded, sh.Slugs[slug].Degraded...)
+	}
+	return degraded
+}
+
+// Summarize evaluates the status of each component and rolls it up per slug and
+// for the server as a whole.
+func Summarize(serverID string, components []*rivets.Component) *ServerHealth {
+	sh := &ServerHealth{
+		ServerID: serverID,
+		Status:   StatusOK,
+		Slugs:    map[string]*SlugHealth{},
+	}
+
+	for _, c := range components {
+		slug, ok := sh.Slugs[c.Name]
+		if !ok {
+			slug = &SlugHealth{Status: StatusOK}
+			sh.Slugs[c.Name] = slug
+		}
+		slug.Count++
+
+		ch := Evaluate(c)
+		slug.Status = Worse(slug.Status, ch.Status)
+		sh.Status = Worse(sh.Status, ch.Status)
+
+		if ch.Status == StatusWarning || ch.Status == StatusCritical {
+			slug.Degraded = append(slug.Degraded, ch)
+		}
+	}
+
+	for _, slug := range sh.Slugs {
+		sort.Slice(slug.Degraded, func(i, j int) bool {
+			return slug.Degraded[i].Serial < slug.Degraded[j].Serial
+		})
+	}
+
+	return sh
+}
+
+// Evaluate determines the health of a single component from its reported status
+// and, for drives, its SMART attributes.
+func Evaluate(c *rivets.Component) *ComponentHealth {
+	ch := &ComponentHealth{
+		Slug:   c.Name,
+		Serial: c.Serial,
+		Vendor: c.Vendor,
+		Model:  c.Model,
+		Status: StatusUnknown,
+	}
+
+	if c.Attributes != nil {
+		ch.Slot = c.Attributes.Slot
+	}
+
+	if c.Status != nil {
+		ch.Health = c.Status.Health
+		ch.State = c.Status.State
+
+		// an empty slot has nothing to be healthy or unhealthy about
+		if strings.EqualFold(c.Status.State, stateAbsent) {
+			return ch
+		}
+
+		if status := fromRedfishHealth(c.Status.Health); status != StatusUnknown {
+			ch.Status = status
+			if status != StatusOK {
+				ch.Reasons = append(ch.Reasons, "reported health "+c.Status.Health)
+			}
+		}
+	}
+
+	if c.Name == common.SlugDrive && c.Attributes != nil {
+		evaluateSmart(ch, c.Attributes)
+	}
+
+	return ch
+}
+
+func evaluateSmart(ch *ComponentHealth, attrs *rivets.ComponentAttributes) {
+	switch strings.ToLower(attrs.SmartStatus) {
+	case common.SmartStatusOK:
+		ch.Status = Worse(ch.Status, StatusOK)
+	case common.SmartStatusFailed:
+		ch.Status = StatusCritical
+		ch.Reasons = append(ch.Reasons, "SMART status failed")
+	}
+
+	if len(attrs.SmartErrors) > 0 {
+		ch.Status = Worse(ch.Status, StatusWarning)
+		for _, e := range attrs.SmartErrors {
+			ch.Reasons = append(ch.Reasons, "SMART error: "+e)
+		}
+	}
+}
+
+// fromRedfishHealth maps the redfish Health property onto a Status.
+func fromRedfishHealth(h string) Status {
+	switch strings.ToLower(strings.TrimSpace(h)) {
+	case "ok":
+		return StatusOK
+	case "warning":
+		return StatusWarning
+	case "critical":
+		return StatusCritical
+	default:
+		return StatusUnknown
+	}
+}
+
+// DegradedComponent is a non-OK component attributed to the server it was found in.
+type DegradedComponent struct {
+	ServerID string `json:"server_id"`
+	ComponentHealth
+}
+
+// Report lists the degraded components found across a set of servers.
+type Report struct {
+	Facility       string               `json:"facility,omitempty"`
+	Mode           string               `json:"mode"`
+	ServersScanned int                  `json:"servers_scanned"`
+	Degraded       []*DegradedComponent `json:"degraded"`
+	// Errors maps the id of a server that could not be evaluated to the reason
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Add includes the degraded components of a server in the report.
+func (r *Report) Add(sh *ServerHealth) {
+	r.ServersScanned++
+	for _, ch := range sh.Degraded() {
+		r.Degraded = append(r.Degraded, &DegradedComponent{
+			ServerID:        sh.ServerID,
+			ComponentHealth: *ch,
+		})
+	}
+}
+
+// AddError records a server that could not be evaluated.
+func (r *Report) AddError(serverID string, err error) {
+	if r.Errors == nil {
+		r.Errors = map[string]string{}
+	}
+	r.Errors[serverID] = err.Error()
+}
+
+// Sort orders the degraded components by server, slug and serial.
+func (r *Report) Sort() {
+	sort.Slice(r.Degraded, func(i, j int) bool {
+		a, b := r.Degraded[i], r.Degraded[j]
+		if a.ServerID != b.ServerID {
+			return a.ServerID < b.ServerID
+		}
+		if a.Slug != b.Slug {
+			return a.Slug < b.Slug
+		}
+		return a.Serial < b.Serial
+	})
+}
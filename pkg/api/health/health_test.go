@@ -0,0 +1,140 @@
+package health
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		component *rivets.Component
+		want      Status
+	}{
+		{
+			name:      "no status",
+			component: &rivets.Component{Name: common.SlugCPU},
+			want:      StatusUnknown,
+		},
+		{
+			name: "healthy",
+			component: &rivets.Component{
+				Name:   common.SlugCPU,
+				Status: &common.Status{Health: "OK", State: "Enabled"},
+			},
+			want: StatusOK,
+		},
+		{
+			name: "critical",
+			component: &rivets.Component{
+				Name:   common.SlugPSU,
+				Status: &common.Status{Health: "Critical", State: "Enabled"},
+			},
+			want: StatusCritical,
+		},
+		{
+			name: "absent",
+			component: &rivets.Component{
+				Name:   common.SlugPhysicalMem,
+				Status: &common.Status{Health: "Critical", State: "Absent"},
+			},
+			want: StatusUnknown,
+		},
+		{
+			name: "smart failed",
+			component: &rivets.Component{
+				Name:       common.SlugDrive,
+				Status:     &common.Status{Health: "OK"},
+				Attributes: &rivets.ComponentAttributes{SmartStatus: "failed"},
+			},
+			want: StatusCritical,
+		},
+		{
+			name: "smart errors",
+			component: &rivets.Component{
+				Name:       common.SlugDrive,
+				Attributes: &rivets.ComponentAttributes{SmartStatus: "ok", SmartErrors: []string{"reallocated sectors"}},
+			},
+			want: StatusWarning,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.want, Evaluate(tc.component).Status)
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+	components := []*rivets.Component{
+		{
+			Name:   common.SlugCPU,
+			Serial: "0",
+			Status: &common.Status{Health: "OK"},
+		},
+		{
+			Name:   common.SlugDrive,
+			Serial: "b",
+			Attributes: &rivets.ComponentAttributes{
+				Slot:        "bay 2",
+				SmartErrors: []string{"pending sectors"},
+			},
+		},
+		{
+			Name:   common.SlugDrive,
+			Serial: "a",
+			Status: &common.Status{Health: "OK"},
+		},
+	}
+	got := Summarize("server", components)
+	require.Equal(t, StatusWarning, got.Status)
+	require.Equal(t, StatusOK, got.Slugs[common.SlugCPU].Status)
+	require.Equal(t, 2, got.Slugs[common.SlugDrive].Count)
+	require.Equal(t, StatusWarning, got.Slugs[common.SlugDrive].Status)
+
+	degraded := got.Degraded()
+	require.Equal(t, 1, len(degraded))
+	require.Equal(t, "b", degraded[0].Serial)
+	require.Equal(t, "bay 2", degraded[0].Slot)
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	critical := &common.Status{Health: "Critical", State: "Enabled"}
+	report := &Report{}
+
+	report.Add(Summarize("b", []*rivets.Component{
+		{Name: common.SlugPSU, Serial: "p1", Status: critical},
+		{Name: common.SlugDrive, Serial: "d2", Status: critical},
+		{Name: common.SlugDrive, Serial: "d1", Status: critical},
+	}))
+	report.Add(Summarize("a", []*rivets.Component{
+		{Name: common.SlugCPU, Serial: "c1", Status: critical},
+		{Name: common.SlugCPU, Serial: "c2", Status: &common.Status{Health: "OK", State: "Enabled"}},
+	}))
+	report.Add(Summarize("c", nil))
+	report.AddError("d", errors.New("not found"))
+
+	require.Equal(t, 3, report.ServersScanned)
+	require.Equal(t, map[string]string{"d": "not found"}, report.Errors)
+
+	report.Sort()
+	got := []string{}
+	for _, d := range report.Degraded {
+		got = append(got, d.ServerID+"/"+d.Slug+"/"+d.Serial)
+	}
+	require.Equal(t, []string{
+		"a/" + common.SlugCPU + "/c1",
+		"b/" + common.SlugDrive + "/d1",
+		"b/" + common.SlugDrive + "/d2",
+		"b/" + common.SlugPSU + "/p1",
+	}, got)
+}
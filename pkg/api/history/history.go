@@ -0,0 +1,39 @@
+package history
+
+import (
+	"time"
+
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// Namespace is the FleetDB versioned attribute namespace holding the inventory
+// history of a server.
+const Namespace = "sh.hollow.component_inventory.history"
+
+// Action is a change made to the inventory of a server through the API.
+type Action string
+
+const (
+	// ActionDeleteComponents removed all the components of a server.
+	ActionDeleteComponents Action = "delete-components"
+	// ActionDeleteComponent removed a single component of a server.
+	ActionDeleteComponent Action = "delete-component"
+	// ActionSetOverrides replaced the operator-maintained data of a server.
+	ActionSetOverrides Action = "set-overrides"
+)
+
+// Entry records a change made to the inventory of a server.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+	// Mode is the inventory changed, empty for changes to both
+	Mode string `json:"mode,omitempty"`
+	// Actor is the JWT subject of the caller, if auth is enabled
+	Actor     string `json:"actor,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Components are the components as they were before the change
+	Components []*rivets.Component `json:"components"`
+	// Annotations are the annotations as they were before the change, if it
+	// replaced them
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
@@ -0,0 +1,79 @@
+package overrides
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/metal-toolbox/component-inventory/internal/componentkey"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+// Namespace is the FleetDB attribute namespace holding the overrides of a server.
+const Namespace = "sh.hollow.component_inventory.overrides"
+
+// ErrInvalid is returned for overrides that can't be stored.
+var ErrInvalid = errors.New("invalid overrides")
+
+// Set is the operator-maintained data of a server, kept across inventory
+// submissions.
+type Set struct {
+	// Components are components collectors can't discover. They are added to
+	// every stored inventory, replacing any collected component with the same
+	// slug and serial.
+	Components []*rivets.Component `json:"components"`
+	// Annotations are free-form notes, e.g. asset tags or RMA ticket numbers
+	Annotations map[string]string `json:"annotations"`
+	UpdatedAt   time.Time         `json:"updated,omitempty"`
+	// UpdatedBy is the JWT subject of the last caller to set them, if auth is enabled
+	UpdatedBy string `json:"updated_by,omitempty"`
+}
+
+// Validate checks that every component is identified by a slug and a serial,
+// only once, and that no annotation has an empty key.
+func (s *Set) Validate() error {
+	seen := make(map[string]struct{}, len(s.Components))
+	for idx, c := range s.Components {
+		if c == nil || c.Name == "" || c.Serial == "" {
+			return fmt.Errorf("%w: component %d needs a name and a serial", ErrInvalid, idx)
+		}
+
+		key := componentkey.Of(c)
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("%w: component %s %s is listed more than once", ErrInvalid, c.Name, c.Serial)
+		}
+		seen[key] = struct{}{}
+	}
+
+	for k := range s.Annotations {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("%w: annotation keys can't be empty", ErrInvalid)
+		}
+	}
+
+	return nil
+}
+
+// Apply adds the components of the set to srv, replacing collected components
+// with the same slug and serial. The components of the set are copied, srv
+// doesn't share them.
+func (s *Set) Apply(srv *rivets.Server) {
+	if s == nil || len(s.Components) == 0 {
+		return
+	}
+
+	index := make(map[string]int, len(srv.Components))
+	for idx, c := range srv.Components {
+		index[componentkey.Of(c)] = idx
+	}
+
+	for _, c := range s.Components {
+		manual := *c
+		if idx, ok := index[componentkey.Of(c)]; ok {
+			srv.Components[idx] = &manual
+			continue
+		}
+		srv.Components = append(srv.Components, &manual)
+	}
+}
@@ -0,0 +1,90 @@
+package overrides
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		set  *Set
+		ok   bool
+	}{
+		{
+			name: "empty",
+			set:  &Set{},
+			ok:   true,
+		},
+		{
+			name: "components and annotations",
+			set: &Set{
+				Components: []*rivets.Component{
+					{Name: "riser", Serial: "r1"},
+					{Name: common.SlugDrive, Serial: "d1"},
+				},
+				Annotations: map[string]string{"rma": "RMA-1234"},
+			},
+			ok: true,
+		},
+		{
+			name: "no serial",
+			set:  &Set{Components: []*rivets.Component{{Name: "riser"}}},
+		},
+		{
+			name: "duplicate component",
+			set: &Set{Components: []*rivets.Component{
+				{Name: "riser", Serial: "r1"},
+				{Name: "RISER", Serial: "r1"},
+			}},
+		},
+		{
+			name: "empty annotation key",
+			set:  &Set{Annotations: map[string]string{" ": "note"}},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.set.Validate()
+			if tc.ok {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, ErrInvalid))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	set := &Set{Components: []*rivets.Component{
+		{Name: common.SlugDrive, Serial: "d1", Model: "manual"},
+		{Name: "riser", Serial: "r1"},
+	}}
+	srv := &rivets.Server{Components: []*rivets.Component{
+		{Name: common.SlugCPU, Serial: "0"},
+		{Name: common.SlugDrive, Serial: "d1", Model: "collected"},
+	}}
+
+	set.Apply(srv)
+	require.Len(t, srv.Components, 3)
+	require.Equal(t, "manual", srv.Components[1].Model)
+	require.Equal(t, "riser", srv.Components[2].Name)
+
+	// the stored inventory doesn't share the components of the set
+	srv.Components[2].Model = "changed"
+	require.Empty(t, set.Components[1].Model)
+
+	// a nil set changes nothing
+	var none *Set
+	none.Apply(srv)
+	require.Len(t, srv.Components, 3)
+}
@@ -1,13 +1,23 @@
 package routes
 
 import (
+	"reflect"
+
 	rivets "github.com/metal-toolbox/rivets/types"
 	"go.uber.org/zap"
 )
 
+// componentChanges counts the components of each slug that were added, removed
+// or changed between two versions of a server's inventory.
+type componentChanges struct {
+	Added   map[string]int
+	Removed map[string]int
+	Changed map[string]int
+}
+
 // compareComponents compares components between two rivets.Server.
-// It logs differences and return false if there are differences.
-func compareComponents(fleetServer, alloyServer *rivets.Server, log *zap.Logger) {
+// It logs differences and returns the changes per component slug.
+func compareComponents(fleetServer, alloyServer *rivets.Server, log *zap.Logger) *componentChanges {
 	alloyMap := componentsToMap(alloyServer.Components)
 	fleetMap := componentsToMap(fleetServer.Components)
 	log.Debug("enumerating incoming")
@@ -24,6 +34,54 @@ func compareComponents(fleetServer, alloyServer *rivets.Server, log *zap.Logger)
 			zap.Int("component.count", len(v)),
 		).Debug("existing component")
 	}
+
+	changes := &componentChanges{
+		Added:   map[string]int{},
+		Removed: map[string]int{},
+		Changed: map[string]int{},
+	}
+
+	for slug, incoming := range alloyMap {
+		existing := bySerial(fleetMap[slug])
+		for _, c := range incoming {
+			old, ok := existing[c.Serial]
+			switch {
+			case !ok:
+				changes.Added[slug]++
+			case !sameComponent(old, c):
+				changes.Changed[slug]++
+			}
+		}
+	}
+
+	for slug, existing := range fleetMap {
+		incoming := bySerial(alloyMap[slug])
+		for _, c := range existing {
+			if _, ok := incoming[c.Serial]; !ok {
+				changes.Removed[slug]++
+			}
+		}
+	}
+
+	return changes
+}
+
+// sameComponent compares the collected properties of two components, ignoring
+// the fields that are assigned by the store.
+func sameComponent(a, b *rivets.Component) bool {
+	return a.Vendor == b.Vendor &&
+		a.Model == b.Model &&
+		reflect.DeepEqual(a.Firmware, b.Firmware) &&
+		reflect.DeepEqual(a.Status, b.Status) &&
+		reflect.DeepEqual(a.Attributes, b.Attributes)
+}
+
+func bySerial(cs []*rivets.Component) map[string]*rivets.Component {
+	m := make(map[string]*rivets.Component, len(cs))
+	for _, c := range cs {
+		m[c.Serial] = c
+	}
+	return m
 }
 
 type componentMap map[string][]*rivets.Component
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompareComponents(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "a", Model: "spinny"},
+			{Name: common.SlugDrive, Serial: "b", Model: "spinny"},
+			{Name: common.SlugGPU, Serial: "g"},
+		},
+	}
+	incoming := &rivets.Server{
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "a", Model: "spinny"},
+			{Name: common.SlugDrive, Serial: "b", Model: "spinny 2"},
+			{Name: common.SlugDrive, Serial: "c", Model: "spinny"},
+		},
+	}
+	got := compareComponents(existing, incoming, zap.NewNop())
+	require.Equal(t, map[string]int{common.SlugDrive: 1}, got.Added)
+	require.Equal(t, map[string]int{common.SlugDrive: 1}, got.Changed)
+	require.Equal(t, map[string]int{common.SlugGPU: 1}, got.Removed)
+}
@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+)
+
+// decompressBody decodes a gzip or zstd request body, failing rather than
+// expanding it to more than maxBytes. Bodies without a content coding are
+// returned as they are.
+func decompressBody(ctx *gin.Context, body []byte, maxBytes int64) ([]byte, bool) {
+	encoding := strings.ToLower(strings.TrimSpace(ctx.GetHeader(constants.ContentEncodingHeader)))
+	if encoding == "" || encoding == compression.Identity {
+		return body, true
+	}
+
+	decoded, err := compression.Decompress(encoding, bytes.NewReader(body), maxBytes)
+	if err != nil {
+		switch {
+		case errors.Is(err, compression.ErrUnsupported):
+			reject(ctx, http.StatusUnsupportedMediaType, "unsupported content encoding", encoding)
+		case errors.Is(err, compression.ErrTooLarge):
+			reject(ctx, http.StatusRequestEntityTooLarge, "request body too large", fmt.Sprintf("decompressed bodies are limited to %d bytes", maxBytes))
+		default:
+			reject(ctx, http.StatusBadRequest, "invalid compressed request body", err.Error())
+		}
+		return nil, false
+	}
+
+	// the handlers read the body as if it was sent uncompressed
+	ctx.Request.Header.Del(constants.ContentEncodingHeader)
+	return decoded, true
+}
+
+// composeCompression compresses responses of at least minBytes for clients
+// accepting gzip or zstd.
+func composeCompression(minBytes int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// the body depends on the Accept-Encoding header, caches have to know
+		ctx.Writer.Header().Add("Vary", constants.AcceptEncodingHeader)
+
+		encoding := compression.Negotiate(ctx.GetHeader(constants.AcceptEncodingHeader))
+		if encoding == "" || ctx.Request.Method == http.MethodHead {
+			return
+		}
+
+		// not deferred, what is held back of a response that panicked is dropped
+		w := &compressWriter{ResponseWriter: ctx.Writer, encoding: encoding, minBytes: minBytes}
+		ctx.Writer = w
+		ctx.Next()
+
+		// an error here means the client went away, there is no one to tell
+		_ = w.finish()
+		ctx.Writer = w.ResponseWriter
+	}
+}
+
+// compressWriter holds back the start of a response until it is large enough
+// to be worth compressing, then compresses the rest of it as it is written.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minBytes int
+	buf      []byte
+	// zw is set once the response is being compressed
+	zw compression.Writer
+	// passthrough is set once the response is being sent as is
+	passthrough bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	switch {
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	case w.zw != nil:
+		return w.zw.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) < w.minBytes {
+		return len(data), nil
+	}
+
+	if err := w.start(true); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Unwrap lets an http.ResponseController reach the connection, e.g. to extend
+// the write deadline of a response.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports whether a response has been started, held back or not.
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.zw != nil || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.passthrough && w.zw == nil {
+		// too late to wait for more
+		_ = w.start(len(w.buf) >= w.minBytes)
+	}
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start sends the held back part of the response, compressing it and what
+// follows if asked to and the handler didn't encode the response itself.
+func (w *compressWriter) start(compress bool) error {
+	header := w.Header()
+	if !compress || header.Get(constants.ContentEncodingHeader) != "" || !bodyAllowed(w.Status()) {
+		w.passthrough = true
+		buf := w.buf
+		w.buf = nil
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	header.Set(constants.ContentEncodingHeader, w.encoding)
+	header.Del("Content-Length")
+
+	zw, err := compression.NewWriter(w.encoding, w.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	w.zw = zw
+
+	buf := w.buf
+	w.buf = nil
+	_, err = w.zw.Write(buf)
+	return err
+}
+
+// finish sends what is left of the response once the handlers are done.
+func (w *compressWriter) finish() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	if !w.passthrough {
+		return w.start(false)
+	}
+	return nil
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
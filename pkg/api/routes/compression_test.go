@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+	large := strings.Repeat(`{"name": "Drive", "vendor": "Micron"},`, 100)
+
+	g := gin.New()
+	g.Use(composeCompression(1024))
+	g.GET("/large", func(ctx *gin.Context) { ctx.String(http.StatusOK, large) })
+	g.GET("/small", func(ctx *gin.Context) { ctx.String(http.StatusOK, "small") })
+	g.GET("/none", func(ctx *gin.Context) { ctx.Status(http.StatusNotModified) })
+	g.GET("/encoded", func(ctx *gin.Context) {
+		ctx.Header(constants.ContentEncodingHeader, compression.Gzip)
+		ctx.String(http.StatusOK, large)
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		encoding string
+		body     string
+		code     int
+	}{
+		{name: "gzip", path: "/large", accept: "gzip", encoding: compression.Gzip, body: large, code: http.StatusOK},
+		{name: "zstd", path: "/large", accept: "gzip, zstd", encoding: compression.Zstd, body: large, code: http.StatusOK},
+		{name: "not accepted", path: "/large", body: large, code: http.StatusOK},
+		{name: "small", path: "/small", accept: "zstd", body: "small", code: http.StatusOK},
+		{name: "no body", path: "/none", accept: "zstd", code: http.StatusNotModified},
+		{name: "encoded by the handler", path: "/encoded", accept: "zstd", encoding: compression.Gzip, body: large, code: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, tc.path, http.NoBody)
+			if tc.accept != "" {
+				req.Header.Set(constants.AcceptEncodingHeader, tc.accept)
+			}
+			rec := httptest.NewRecorder()
+			g.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.code, rec.Code)
+			require.Contains(t, rec.Header().Values("Vary"), constants.AcceptEncodingHeader)
+			require.Equal(t, tc.encoding, rec.Header().Get(constants.ContentEncodingHeader))
+
+			body := rec.Body.Bytes()
+			if tc.encoding != "" && tc.path != "/encoded" {
+				var err error
+				body, err = compression.Decompress(tc.encoding, bytes.NewReader(body), 1<<20)
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.body, string(body))
+		})
+	}
+}
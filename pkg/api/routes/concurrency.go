@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/fingerprint"
+	"github.com/metal-toolbox/component-inventory/internal/serverlock"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// how long an update waits for another update of the same server to finish
+var serverLockTimeout = 10 * time.Second
+
+// errServerBusy is returned when another update of a server held its lock for
+// as long as an update waits for it.
+var errServerBusy = errors.New("server is being updated by another request")
+
+// lockServer serializes updates to a server, so that concurrent submissions
+// don't interleave their read-compare-write of its inventory. It fails with
+// errServerBusy when the lock stays held for serverLockTimeout, and with the
+// error of ctx when the request is cancelled or runs out of time first. Other
+// errors are lock failures.
+func lockServer(ctx context.Context, locker serverlock.Locker, serverID uuid.UUID) (func(), error) {
+	ctx, span := tracer.Start(ctx, "server.lock",
+		trace.WithAttributes(attribute.String("server.id", serverID.String())),
+	)
+	defer span.End()
+
+	lockCtx, cancel := context.WithTimeout(ctx, serverLockTimeout)
+	defer cancel()
+
+	unlock, err := locker.Lock(lockCtx, serverID.String())
+	if err == nil {
+		return unlock, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("server lock: %w", ctx.Err())
+	}
+	if errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+		// the wait ran out
+		return nil, fmt.Errorf("%w: %v", errServerBusy, err)
+	}
+	return nil, err
+}
+
+// rejectLockFailure responds to an update that couldn't lock its server.
+func rejectLockFailure(ctx *gin.Context, logger *zap.Logger, err error) {
+	if errors.Is(err, errServerBusy) {
+		logger.With(zap.Error(err)).Warn("server lock")
+		reject(ctx, http.StatusConflict, "server is being updated by another request", err.Error())
+		return
+	}
+
+	// the request went away or ran out of time, the lock isn't at fault
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		logger.With(zap.Error(err)).Warn("server lock")
+		reject(ctx, http.StatusServiceUnavailable, "unable to lock server", err.Error())
+		return
+	}
+
+	logger.With(zap.Error(err)).Error("server lock")
+	reject(ctx, http.StatusServiceUnavailable, "unable to lock server", err.Error())
+}
+
+// componentsETag returns the entity tag of a server's components served in a
+// content type, one of componentsFormats. It only depends on the collected
+// facts of the components and the content type, so it is stable across stores
+// of the same inventory. It is weak, as the same tag is sent whatever content
+// coding the response is compressed with.
+func componentsETag(cs []*rivets.Component, format string) string {
+	return `W/"` + fingerprint.Components(cs) + "-" + formatTag(format) + `"`
+}
+
+// formatTag names a content type in entity tags, the YAML and protobuf ones
+// in either of their spellings the same.
+func formatTag(format string) string {
+	switch format {
+	case constants.CSVContentType:
+		return "csv"
+	case constants.YAMLContentType, binding.MIMEYAML:
+		return "yaml"
+	case constants.ProtobufContentType, "application/protobuf":
+		return "protobuf"
+	}
+	return "json"
+}
+
+// etagFingerprint returns the fingerprint of the components an entity tag
+// was made for, whatever content type it was made for.
+func etagFingerprint(etag string) string {
+	etag = strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	fp, _, _ := strings.Cut(etag, "-")
+	return fp
+}
+
+// setComponentsETag sets the ETag header of a response about the given
+// components, for the content type they are or would be served in.
+func setComponentsETag(ctx *gin.Context, cs []*rivets.Component, format string) {
+	ctx.Header(constants.ETagHeader, componentsETag(cs, format))
+}
+
+// ifMatch reports whether the If-Match header of the request, if any, matches
+// the given components. An update is guarded by the components rather than by
+// one of their representations, so tags are compared by the components they
+// were made for: any content type, weak or not.
+func ifMatch(ctx *gin.Context, cs []*rivets.Component) bool {
+	header := ctx.GetHeader(constants.IfMatchHeader)
+	if header == "" {
+		return true
+	}
+
+	fp := fingerprint.Components(cs)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || etagFingerprint(candidate) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// ifNoneMatch reports whether the If-None-Match header of the request matches the
+// given entity tag, meaning the caller already has the current representation.
+// Tags are compared weakly, by their value, as RFC 9110 requires.
+func ifNoneMatch(ctx *gin.Context, etag string) bool {
+	header := ctx.GetHeader(constants.IfNoneMatchHeader)
+	if header == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setLastModified sets the Last-Modified header to the latest update of the
+// given components, if any of them has one. It is informational only: removing
+// a component doesn't move it, so requests are not evaluated against it.
+func setLastModified(ctx *gin.Context, cs []*rivets.Component) {
+	var latest time.Time
+	for _, c := range cs {
+		if c != nil && c.UpdatedAt.After(latest) {
+			latest = c.UpdatedAt
+		}
+	}
+
+	if !latest.IsZero() {
+		ctx.Header(constants.LastModifiedHeader, latest.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/serverlock"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestComponentsETag(t *testing.T) {
+	t.Parallel()
+	stored := []*rivets.Component{
+		{ID: "a", Name: "drive", Serial: "d1", Vendor: "acme"},
+		{ID: "b", Name: "cpu", Serial: "c1", Vendor: "acme"},
+	}
+	submitted := []*rivets.Component{
+		{Name: "cpu", Serial: "c1", Vendor: "acme"},
+		{Name: "drive", Serial: "d1", Vendor: "acme"},
+	}
+	etag := componentsETag(stored, binding.MIMEJSON)
+	require.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	require.Equal(t, etag, componentsETag(submitted, binding.MIMEJSON))
+
+	// each content type has its own tag
+	require.NotEqual(t, etag, componentsETag(stored, constants.CSVContentType))
+	require.NotEqual(t, etag, componentsETag(stored, constants.ProtobufContentType))
+	require.Equal(t, componentsETag(stored, binding.MIMEYAML), componentsETag(stored, constants.YAMLContentType))
+
+	submitted[1].Vendor = "other"
+	require.NotEqual(t, etag, componentsETag(submitted, binding.MIMEJSON))
+}
+
+func TestIfMatch(t *testing.T) {
+	t.Parallel()
+	cs := []*rivets.Component{{Name: "drive", Serial: "d1"}}
+	etag := componentsETag(cs, binding.MIMEJSON)
+	tests := []struct {
+		name   string
+		header string
+		match  bool
+	}{
+		{"no header", "", true},
+		{"same tag", etag, true},
+		{"any tag", "*", true},
+		{"one of", `W/"xyz-json", ` + etag, true},
+		{"other tag", `W/"xyz-json"`, false},
+		{"other content type", componentsETag(cs, constants.CSVContentType), true},
+		{"strong tag", strings.TrimPrefix(etag, "W/"), true},
+		{"other components", componentsETag([]*rivets.Component{{Name: "drive", Serial: "d2"}}, binding.MIMEJSON), false},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+			if tc.header != "" {
+				ctx.Request.Header.Set(constants.IfMatchHeader, tc.header)
+			}
+			require.Equal(t, tc.match, ifMatch(ctx, cs))
+		})
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	t.Parallel()
+	etag := `W/"abc-json"`
+	tests := []struct {
+		name   string
+		header string
+		match  bool
+	}{
+		{"no header", "", false},
+		{"same tag", `W/"abc-json"`, true},
+		{"strong tag", `"abc-json"`, true},
+		{"one of", `W/"xyz-json", W/"abc-json"`, true},
+		{"other content type", `W/"abc-csv"`, false},
+		{"other tag", `W/"xyz-json"`, false},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.header != "" {
+				ctx.Request.Header.Set(constants.IfNoneMatchHeader, tc.header)
+			}
+			require.Equal(t, tc.match, ifNoneMatch(ctx, etag))
+		})
+	}
+}
+
+// failingLocker fails to lock without waiting, like a lock backend that is down.
+type failingLocker struct{}
+
+func (failingLocker) Lock(context.Context, string) (func(), error) {
+	return nil, errors.New("lock backend unavailable")
+}
+
+func TestLockServer(t *testing.T) { // not parallel, it sets serverLockTimeout
+	timeout := serverLockTimeout
+	serverLockTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { serverLockTimeout = timeout })
+	serverID := uuid.New()
+	locker := serverlock.NewLocal()
+
+	unlock, err := lockServer(context.Background(), locker, serverID)
+	require.NoError(t, err)
+	defer unlock()
+
+	// a wait that runs out means the lock is held
+	_, err = lockServer(context.Background(), locker, serverID)
+	require.True(t, errors.Is(err, errServerBusy), err)
+
+	// a request going away first is not a busy server
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = lockServer(ctx, locker, serverID)
+	require.True(t, errors.Is(err, context.Canceled), err)
+	require.False(t, errors.Is(err, errServerBusy))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = lockServer(ctx, locker, serverID)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	require.False(t, errors.Is(err, errServerBusy))
+
+	_, err = lockServer(context.Background(), failingLocker{}, serverID)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, errServerBusy))
+}
+
+func TestRejectLockFailure(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"busy", errServerBusy, http.StatusConflict},
+		{"cancelled", fmt.Errorf("server lock: %w", context.Canceled), http.StatusServiceUnavailable},
+		{"failure", errors.New("lock backend unavailable"), http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+			rejectLockFailure(ctx, zap.NewNop(), tc.err)
+			require.Equal(t, tc.code, w.Code)
+		})
+	}
+}
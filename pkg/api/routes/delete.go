@@ -0,0 +1,246 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/zap"
+)
+
+// how often, and how far apart, the components kept by a removal are stored
+// again, and how long it may take. Restoring carries on when the client goes
+// away, the components are missing until it's done.
+var (
+	restoreAttempts = 3
+	restoreBackoff  = 500 * time.Millisecond
+	restoreTimeout  = 30 * time.Second
+)
+
+// composeDeleteComponentsHandler removes all the components of a server from the
+// inventory of one mode, e.g. for a decommissioned server.
+func composeDeleteComponentsHandler(theApp *app.App) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		removeComponents(ctx, theApp, history.ActionDeleteComponents, func(*rivets.Component) bool {
+			return true
+		})
+	}
+}
+
+// composeDeleteComponentHandler removes a single component, identified by its
+// slug and serial, from the inventory of one mode, e.g. for a pulled GPU.
+func composeDeleteComponentHandler(theApp *app.App) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		slug, serial := ctx.Param("slug"), ctx.Param("serial")
+		removeComponents(ctx, theApp, history.ActionDeleteComponent, func(c *rivets.Component) bool {
+			return strings.EqualFold(c.Name, slug) && c.Serial == serial
+		})
+	}
+}
+
+// composeHistoryHandler lists the recorded changes to the inventory of a server.
+func composeHistoryHandler(theApp *app.App) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		serverID, err := uuid.Parse(ctx.Param("server"))
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+			return
+		}
+
+		entries, err := getHistory(ctx.Request.Context(), theApp.FleetDB, serverID)
+		if err != nil {
+			requestLogger(ctx, theApp.Log).With(
+				zap.Error(err),
+				zap.String("server.id", serverID.String()),
+			).Warn("history lookup")
+			reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "history unavailable", err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, entries)
+	}
+}
+
+// removeComponents removes the components of a server selected by remove from
+// the inventory of the requested mode and records the removal in its history.
+//
+// FleetDB only adds and updates components when an inventory is stored, and its
+// client can only delete all the components of a server at once. The component
+// records are shared by both modes, only their attributes are per mode. So all
+// components are deleted, then the ones kept in the requested mode and all the
+// components of the other mode are stored again, with the attributes of each
+// mode. A component removed from one mode is still listed in it while the
+// other mode has it, without attributes for the mode. Restoring isn't atomic:
+// when it fails, the kept components are missing until the next submission.
+func removeComponents(ctx *gin.Context, theApp *app.App, action history.Action, remove func(*rivets.Component) bool) {
+	fdb := theApp.FleetDB
+	logger := requestLogger(ctx, theApp.Log)
+	inband := inbandFromQuery(ctx)
+	mode := modeString(inband)
+
+	serverID, err := uuid.Parse(ctx.Param("server"))
+	if err != nil {
+		reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+		return
+	}
+	logger = logger.With(
+		zap.String("server.id", serverID.String()),
+		zap.String("mode", mode),
+	)
+
+	reqCtx := ctx.Request.Context()
+	unlock, err := lockServer(reqCtx, theApp.ServerLock, serverID)
+	if err != nil {
+		rejectLockFailure(ctx, logger, err)
+		return
+	}
+	defer unlock()
+
+	target, err := getServerInventory(reqCtx, fdb, serverID, inband)
+	if err != nil {
+		logger.With(zap.Error(err)).Warn("server lookup")
+		reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "unable to retrieve server", err.Error())
+		return
+	}
+
+	// the other mode shares the components, it has to be stored again too
+	other, err := getServerInventory(reqCtx, fdb, serverID, !inband)
+	if err != nil {
+		if fleetDBErrorStatus(err, http.StatusInternalServerError) != http.StatusNotFound {
+			logger.With(zap.Error(err)).Warn("server lookup")
+			reject(ctx, http.StatusInternalServerError, "unable to retrieve server", err.Error())
+			return
+		}
+		other = nil
+	}
+
+	removed := []*rivets.Component{}
+	var kept []*rivets.Component
+	for _, c := range target.Components {
+		if remove(c) {
+			removed = append(removed, c)
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if action == history.ActionDeleteComponent && len(removed) == 0 {
+		reject(ctx, http.StatusNotFound, "component not found", "")
+		return
+	}
+
+	entry := &history.Entry{
+		Time:       time.Now().UTC(),
+		Action:     action,
+		Mode:       mode,
+		Actor:      ginjwt.GetSubject(ctx),
+		RequestID:  requestID(ctx),
+		Components: removed,
+	}
+
+	if len(removed) == 0 {
+		ctx.JSON(http.StatusOK, entry)
+		return
+	}
+
+	// record the removal first, the components can't be recovered without it
+	if err := addHistory(reqCtx, fdb, serverID, entry); err != nil {
+		logger.With(zap.Error(err)).Warn("history update")
+		reject(ctx, http.StatusInternalServerError, "unable to record the removal", err.Error())
+		return
+	}
+
+	restore := map[bool]*rivets.Server{}
+	if len(kept) > 0 {
+		srv := *target
+		srv.Components = kept
+		restore[inband] = &srv
+	}
+	if other != nil {
+		// the other mode keeps its components, except removed ones it only
+		// lists for their record, without attributes of its own
+		srv := *other
+		srv.Components = nil
+		for _, c := range other.Components {
+			if c.Attributes != nil || !remove(c) {
+				srv.Components = append(srv.Components, c)
+			}
+		}
+		if len(srv.Components) > 0 {
+			restore[!inband] = &srv
+		}
+	}
+
+	if err := deleteServerComponents(reqCtx, fdb, serverID); err != nil {
+		logger.With(zap.Error(err)).Warn("component removal")
+		reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "unable to remove components", err.Error())
+		return
+	}
+
+	restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(reqCtx), restoreTimeout)
+	err = restoreComponents(restoreCtx, logger, fdb, serverID, restore)
+	cancel()
+	theApp.InventoryCache.Invalidate(serverID, true)
+	theApp.InventoryCache.Invalidate(serverID, false)
+
+	if err != nil {
+		logger.With(zap.Error(err), zap.Int("component.kept", len(kept))).Error("component restore")
+		reject(ctx, http.StatusInternalServerError, "components removed, but the remaining ones couldn't be stored again, they return with the next inventory submission", err.Error())
+		return
+	}
+
+	changes := &componentChanges{Removed: map[string]int{}}
+	for _, c := range removed {
+		changes.Removed[c.Name]++
+	}
+	recordComponentChanges(mode, changes)
+
+	logger.With(
+		zap.String("action", string(action)),
+		zap.Int("component.count", len(removed)),
+	).Info("components removed")
+	ctx.JSON(http.StatusOK, entry)
+}
+
+// restoreComponents stores again the inventories of each mode the removal
+// deleted, retrying the modes that fail.
+func restoreComponents(ctx context.Context, logger *zap.Logger, fdb *fleetdb.Client, serverID uuid.UUID, restore map[bool]*rivets.Server) error {
+	for attempt := 1; ; attempt++ {
+		var err error
+		for inband, srv := range restore {
+			if setErr := setServerInventory(ctx, fdb, serverID, srv, inband); setErr != nil {
+				err = setErr
+				logger.With(
+					zap.Error(setErr),
+					zap.String("restore.mode", modeString(inband)),
+					zap.Int("attempt", attempt),
+				).Warn("component restore")
+				continue
+			}
+			delete(restore, inband)
+		}
+
+		if len(restore) == 0 {
+			return nil
+		}
+		if attempt >= restoreAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(restoreBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+// withComponents adds a server to fake with the same drives in both modes, set
+// apart by the slot in their attributes.
+func withComponents(fake *fakeFleetDB, serials ...string) uuid.UUID {
+	serverID := uuid.New()
+	fake.addServer(serverID)
+
+	for _, inband := range []bool{true, false} {
+		srv := &rivets.Server{}
+		for _, serial := range serials {
+			srv.Components = append(srv.Components, &rivets.Component{
+				Name:       "drive",
+				Serial:     serial,
+				Attributes: &rivets.ComponentAttributes{Slot: modeString(inband)},
+			})
+		}
+		fake.servers[serverID.String()].store(srv, inband)
+	}
+	return serverID
+}
+
+func serials(components []*rivets.Component) []string {
+	s := []string{}
+	for _, c := range components {
+		s = append(s, c.Serial)
+	}
+	return s
+}
+
+func serveRequest(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, http.NoBody))
+	return w
+}
+
+// attributeSlots returns the slot in the attributes of each component by
+// serial, empty for components without attributes.
+func attributeSlots(components []*rivets.Component) map[string]string {
+	slots := map[string]string{}
+	for _, c := range components {
+		slots[c.Serial] = ""
+		if c.Attributes != nil {
+			slots[c.Serial] = c.Attributes.Slot
+		}
+	}
+	return slots
+}
+
+func TestDeleteComponent(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d1", "d2", "d3")
+	path := constants.ComponentsEndpoint + "/" + serverID.String()
+
+	w := serveRequest(h, http.MethodDelete, path+"/DRIVE/d2?mode=outofband")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	entry := &history.Entry{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), entry))
+	require.Equal(t, history.ActionDeleteComponent, entry.Action)
+	require.Equal(t, constants.OutOfBandMode, entry.Mode)
+	require.Equal(t, []string{"d2"}, serials(entry.Components))
+
+	// the records are shared, the component is only left without out-of-band
+	// attributes while the inband inventory keeps it
+	outofband, inband := modeString(false), modeString(true)
+	require.Equal(t, map[string]string{"d1": outofband, "d2": "", "d3": outofband}, attributeSlots(fake.components(serverID, false)))
+	require.Equal(t, map[string]string{"d1": inband, "d2": inband, "d3": inband}, attributeSlots(fake.components(serverID, true)))
+
+	w = serveRequest(h, http.MethodDelete, path+"/drive/d9?mode=outofband")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveRequest(h, http.MethodGet, path+constants.HistoryPath)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	entries := []*history.Entry{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, []string{"d2"}, serials(entries[0].Components))
+}
+
+func TestDeleteComponents(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d1", "d2")
+	path := constants.ComponentsEndpoint + "/" + serverID.String()
+
+	// the inband inventory survives the removal of the out-of-band one
+	w := serveRequest(h, http.MethodDelete, path+"?mode=outofband")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	inband := modeString(true)
+	require.Equal(t, map[string]string{"d1": inband, "d2": inband}, attributeSlots(fake.components(serverID, true)))
+	require.Equal(t, map[string]string{"d1": "", "d2": ""}, attributeSlots(fake.components(serverID, false)))
+
+	w = serveRequest(h, http.MethodDelete, path+"?mode=inband")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Empty(t, fake.components(serverID, true))
+	require.Empty(t, fake.components(serverID, false))
+
+	// nothing left to remove, nothing recorded
+	w = serveRequest(h, http.MethodDelete, path)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveRequest(h, http.MethodGet, path+constants.HistoryPath)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	entries := []*history.Entry{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		require.Equal(t, history.ActionDeleteComponents, e.Action)
+		require.ElementsMatch(t, []string{"d1", "d2"}, serials(e.Components))
+	}
+
+	w = serveRequest(h, http.MethodDelete, constants.ComponentsEndpoint+"/"+uuid.NewString())
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteComponentRestoreRetried(t *testing.T) { // not parallel, see newTestHandler
+	backoff := restoreBackoff
+	restoreBackoff = 0
+	t.Cleanup(func() { restoreBackoff = backoff })
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d1", "d2", "d3")
+	path := constants.ComponentsEndpoint + "/" + serverID.String()
+
+	failures := 1
+	fake.fail = func(r *http.Request) bool {
+		if r.Method != http.MethodPut || !strings.Contains(r.URL.Path, "/inventory/") || failures == 0 {
+			return false
+		}
+		failures--
+		return true
+	}
+
+	w := serveRequest(h, http.MethodDelete, path+"/drive/d1?mode=outofband")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.ElementsMatch(t, []string{"d1", "d2", "d3"}, serials(fake.components(serverID, true)))
+	require.Equal(t, map[string]string{"d1": "", "d2": modeString(false), "d3": modeString(false)}, attributeSlots(fake.components(serverID, false)))
+
+	// restores that keep failing are reported, both modes are retried each time
+	fake.mu.Lock()
+	failures = 2 * restoreAttempts
+	fake.mu.Unlock()
+	w = serveRequest(h, http.MethodDelete, path+"/drive/d2?mode=outofband")
+	require.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+}
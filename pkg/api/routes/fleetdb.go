@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/inventorycache"
+	"github.com/metal-toolbox/component-inventory/internal/metrics"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	"github.com/metal-toolbox/component-inventory/pkg/api/overrides"
+	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// the dependency name used in metrics for FleetDB calls
+	fleetDBDependency = "fleetdb"
+	// the cache name used in metrics for the inventory cache
+	inventoryCacheName = "inventory"
+)
+
+// startFleetDBCall opens a span for a FleetDB operation. The returned function
+// ends the span and records the latency and any error of the operation.
+func startFleetDBCall(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "fleetdb."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return ctx, func(err error) {
+		metrics.DependencyCallEpilog(start, fleetDBDependency, operation)
+		if err != nil {
+			metrics.DependencyError(fleetDBDependency, operation)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// fleetDBErrorStatus returns the status code to report for a failed FleetDB call.
+// Resources FleetDB doesn't have are reported as not found, anything else gets
+// the fallback code.
+func fleetDBErrorStatus(err error, fallback int) int {
+	var se fleetdb.ServerError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+// getServer returns the FleetDB record of a server, e.g. to tell an unknown
+// server from one without some attributes.
+func getServer(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID) (*fleetdb.Server, error) {
+	ctx, done := startFleetDBCall(ctx, "get-server", attribute.String("server.id", serverID.String()))
+	srv, _, err := fdb.Get(ctx, serverID)
+	done(err)
+	return srv, err
+}
+
+func getServerInventory(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID, inband bool) (*rivets.Server, error) {
+	ctx, done := startFleetDBCall(ctx, "get-inventory", serverAttributes(serverID, inband)...)
+	srv, _, err := fdb.GetServerInventory(ctx, serverID, inband)
+	if err == nil {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("component.count", len(srv.Components)))
+	}
+	done(err)
+	return srv, err
+}
+
+// getCachedServerInventory serves a server inventory from the cache when it has
+// a current one, and reads it from FleetDB otherwise. It is meant for reads only,
+// updates must compare against what FleetDB has.
+func getCachedServerInventory(ctx context.Context, cache *inventorycache.Cache, fdb *fleetdb.Client, serverID uuid.UUID, inband bool) (*rivets.Server, error) {
+	if cache == nil {
+		return getServerInventory(ctx, fdb, serverID, inband)
+	}
+
+	srv, hit := cache.Get(serverID, inband)
+	metrics.CacheLookup(inventoryCacheName, hit)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("inventory.cache.hit", hit))
+	if hit {
+		return srv, nil
+	}
+
+	srv, err := getServerInventory(ctx, fdb, serverID, inband)
+	if err == nil {
+		cache.Set(serverID, inband, srv)
+	}
+	return srv, err
+}
+
+func setServerInventory(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID, srv *rivets.Server, inband bool) error {
+	attrs := append(serverAttributes(serverID, inband), attribute.Int("component.count", len(srv.Components)))
+	ctx, done := startFleetDBCall(ctx, "set-inventory", attrs...)
+	_, err := fdb.SetServerInventory(ctx, serverID, srv, inband)
+	done(err)
+	return err
+}
+
+func listServers(ctx context.Context, fdb *fleetdb.Client, params *fleetdb.ServerListParams) ([]fleetdb.Server, *fleetdb.ServerResponse, error) {
+	ctx, done := startFleetDBCall(ctx, "list-servers", attribute.String("facility", params.FacilityCode))
+	servers, resp, err := fdb.List(ctx, params)
+	done(err)
+	return servers, resp, err
+}
+
+func deleteServerComponents(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID) error {
+	ctx, done := startFleetDBCall(ctx, "delete-components", attribute.String("server.id", serverID.String()))
+	_, err := fdb.DeleteServerComponents(ctx, serverID)
+	done(err)
+	return err
+}
+
+// addHistory records a change to the inventory of a server in FleetDB.
+func addHistory(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID, entry *history.Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := startFleetDBCall(ctx, "add-history", attribute.String("server.id", serverID.String()))
+	_, err = fdb.CreateVersionedAttributes(ctx, serverID, fleetdb.VersionedAttributes{
+		Namespace: history.Namespace,
+		Data:      data,
+	})
+	done(err)
+	return err
+}
+
+// getHistory returns the recorded changes to the inventory of a server, most
+// recent first.
+func getHistory(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID) ([]*history.Entry, error) {
+	ctx, done := startFleetDBCall(ctx, "get-history", attribute.String("server.id", serverID.String()))
+	attrs, _, err := fdb.GetVersionedAttributes(ctx, serverID, history.Namespace)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*history.Entry, 0, len(attrs))
+	for idx := range attrs {
+		entry := &history.Entry{}
+		if err := json.Unmarshal(attrs[idx].Data, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// getOverrides returns the overrides of a server, and whether FleetDB has any.
+// A server without overrides gets an empty set.
+func getOverrides(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID) (*overrides.Set, bool, error) {
+	ctx, done := startFleetDBCall(ctx, "get-overrides", attribute.String("server.id", serverID.String()))
+	attrs, _, err := fdb.GetAttributes(ctx, serverID, overrides.Namespace)
+	if err != nil {
+		if fleetDBErrorStatus(err, http.StatusInternalServerError) == http.StatusNotFound {
+			done(nil)
+			return &overrides.Set{}, false, nil
+		}
+		done(err)
+		return nil, false, err
+	}
+	done(nil)
+
+	set := &overrides.Set{}
+	if err := json.Unmarshal(attrs.Data, set); err != nil {
+		return nil, true, err
+	}
+	return set, true, nil
+}
+
+// setOverrides stores the overrides of a server, exists tells whether FleetDB
+// already has some to update.
+func setOverrides(ctx context.Context, fdb *fleetdb.Client, serverID uuid.UUID, set *overrides.Set, exists bool) error {
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := startFleetDBCall(ctx, "set-overrides", attribute.String("server.id", serverID.String()))
+	if exists {
+		_, err = fdb.UpdateAttributes(ctx, serverID, overrides.Namespace, data)
+	} else {
+		_, err = fdb.CreateAttributes(ctx, serverID, fleetdb.Attributes{
+			Namespace: overrides.Namespace,
+			Data:      data,
+		})
+	}
+	done(err)
+	return err
+}
@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/internal/componentkey"
+	"github.com/metal-toolbox/component-inventory/internal/inventorycache"
+	"github.com/metal-toolbox/component-inventory/internal/serverlock"
+	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeFleetDB serves the parts of the FleetDB API the handlers use. Like
+// FleetDB, it keeps one set of component records per server for both modes,
+// with attributes per mode, and doesn't return the BIOS configuration stored
+// with an inventory.
+type fakeFleetDB struct {
+	mu      sync.Mutex
+	servers map[string]*fakeServer
+	// inventoryWrites counts the inventories stored
+	inventoryWrites int
+	// fail makes the requests it matches fail with a server error
+	fail func(r *http.Request) bool
+}
+
+type fakeServer struct {
+	// the server properties FleetDB keeps, without components; the facility is
+	// set by tests
+	props rivets.Server
+	// component records in the order they were added
+	keys []string
+	// the components as last stored in each mode, by record
+	modes map[bool]map[string]*rivets.Component
+	// attributes by namespace, versioned ones most recent first
+	attributes map[string]json.RawMessage
+	versioned  map[string][]json.RawMessage
+}
+
+func newFakeFleetDB() *fakeFleetDB {
+	return &fakeFleetDB{servers: map[string]*fakeServer{}}
+}
+
+// addServer adds a server without components.
+func (f *fakeFleetDB) addServer(serverID uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.servers[serverID.String()] = &fakeServer{
+		modes:      map[bool]map[string]*rivets.Component{true: {}, false: {}},
+		attributes: map[string]json.RawMessage{},
+		versioned:  map[string][]json.RawMessage{},
+	}
+}
+
+// components returns the components of a server in a mode, as FleetDB would.
+func (f *fakeFleetDB) components(serverID uuid.UUID, inband bool) []*rivets.Component {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.servers[serverID.String()].inventory(inband).Components
+}
+
+func (s *fakeServer) inventory(inband bool) *rivets.Server {
+	srv := &rivets.Server{
+		Vendor: s.props.Vendor,
+		Model:  s.props.Model,
+		Serial: s.props.Serial,
+		Status: s.props.Status,
+	}
+	for _, key := range s.keys {
+		c, ok := s.modes[inband][key]
+		if !ok {
+			// the record exists, without attributes for this mode
+			other := *s.modes[!inband][key]
+			other.Attributes = nil
+			c = &other
+		}
+		srv.Components = append(srv.Components, c)
+	}
+	return srv
+}
+
+func (s *fakeServer) store(srv *rivets.Server, inband bool) {
+	s.props.Vendor, s.props.Model, s.props.Serial, s.props.Status = srv.Vendor, srv.Model, srv.Serial, srv.Status
+	for _, c := range srv.Components {
+		key := componentkey.Of(c)
+		_, inMode := s.modes[inband][key]
+		_, inOther := s.modes[!inband][key]
+		if !inMode && !inOther {
+			s.keys = append(s.keys, key)
+		}
+	}
+	for _, c := range srv.Components {
+		s.modes[inband][componentkey.Of(c)] = c
+	}
+}
+
+func (f *fakeFleetDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil && f.fail(r) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message": "failure injected"}`))
+		return
+	}
+
+	// /api/v1/<collection>[/<id>[/<sub>[/<namespace>]]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	if len(parts) == 1 && parts[0] == "servers" && r.Method == http.MethodGet {
+		f.listServers(w, r)
+		return
+	}
+	if len(parts) < 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	srv, ok := f.servers[parts[1]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "resource not found"}`))
+		return
+	}
+
+	inband := r.URL.Query().Get("mode") == "inband"
+	route := r.Method + " " + parts[0]
+	if len(parts) > 2 {
+		route += "/" + parts[2]
+	}
+
+	switch route {
+	case "GET servers":
+		writeRecord(w, &fleetdb.Server{UUID: uuid.MustParse(parts[1]), FacilityCode: srv.props.Facility})
+	case "GET inventory":
+		writeRecord(w, srv.inventory(inband))
+	case "PUT inventory":
+		inv := &rivets.Server{}
+		if err := json.NewDecoder(r.Body).Decode(inv); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		srv.store(inv, inband)
+		f.inventoryWrites++
+		writeRecord(w, nil)
+	case "DELETE servers/components":
+		srv.keys = nil
+		srv.modes = map[bool]map[string]*rivets.Component{true: {}, false: {}}
+		writeRecord(w, nil)
+	case "GET servers/attributes":
+		data, ok := srv.attributes[parts[3]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "resource not found"}`))
+			return
+		}
+		writeRecord(w, &fleetdb.Attributes{Namespace: parts[3], Data: data})
+	case "POST servers/attributes":
+		attrs := &fleetdb.Attributes{}
+		_ = json.NewDecoder(r.Body).Decode(attrs)
+		srv.attributes[attrs.Namespace] = attrs.Data
+		writeRecord(w, nil)
+	case "PUT servers/attributes":
+		attrs := &fleetdb.Attributes{}
+		_ = json.NewDecoder(r.Body).Decode(attrs)
+		srv.attributes[parts[3]] = attrs.Data
+		writeRecord(w, nil)
+	case "GET servers/versioned-attributes":
+		records := []fleetdb.VersionedAttributes{}
+		for _, data := range srv.versioned[parts[3]] {
+			records = append(records, fleetdb.VersionedAttributes{Namespace: parts[3], Data: data})
+		}
+		_ = json.NewEncoder(w).Encode(&fleetdb.ServerResponse{Records: records})
+	case "POST servers/versioned-attributes":
+		attrs := &fleetdb.VersionedAttributes{}
+		_ = json.NewDecoder(r.Body).Decode(attrs)
+		srv.versioned[attrs.Namespace] = append([]json.RawMessage{attrs.Data}, srv.versioned[attrs.Namespace]...)
+		writeRecord(w, nil)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// listServers lists the servers of a facility, in a single page.
+func (f *fakeFleetDB) listServers(w http.ResponseWriter, r *http.Request) {
+	facility := r.URL.Query().Get("facility-code")
+	servers := []fleetdb.Server{}
+	for id, srv := range f.servers {
+		if facility == "" || srv.props.Facility == facility {
+			servers = append(servers, fleetdb.Server{UUID: uuid.MustParse(id), FacilityCode: srv.props.Facility})
+		}
+	}
+	_ = json.NewEncoder(w).Encode(&fleetdb.ServerResponse{Records: servers})
+}
+
+func writeRecord(w http.ResponseWriter, record any) {
+	_ = json.NewEncoder(w).Encode(&fleetdb.ServerResponse{Record: record})
+}
+
+// newFleetDBTestHandler composes the API against a fake FleetDB. Tests using it
+// are not parallel, see newTestHandler.
+func newFleetDBTestHandler(t *testing.T, fake *fakeFleetDB) http.Handler {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	fdb, err := fleetdb.NewClient(srv.URL, srv.Client())
+	require.NoError(t, err)
+
+	theApp := &app.App{
+		Log:     zap.NewNop(),
+		FleetDB: fdb,
+		Cfg: &app.Configuration{
+			IdempotencyWindow: time.Hour,
+			RateLimitOpts:     app.RateLimitOptions{Disabled: true},
+			BodyLimitOpts:     app.DefaultBodyLimits,
+		},
+		ServerLock:     serverlock.NewLocal(),
+		InventoryCache: inventorycache.NewCache(16, time.Minute),
+	}
+	return ComposeHTTPServer(theApp).Handler
+}
@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/metal-toolbox/alloy/types"
+	"github.com/metal-toolbox/component-inventory/internal/componentkey"
+	iconv "github.com/metal-toolbox/component-inventory/internal/inventoryconverter"
+	"github.com/metal-toolbox/component-inventory/internal/redfishconverter"
+	"github.com/metal-toolbox/component-inventory/internal/toolconverter"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the format labels of submitted inventories
+const (
+	alloyFormat     = "alloy"
+	redfishFormat   = "redfish"
+	lshwFormat      = "lshw"
+	dmidecodeFormat = "dmidecode"
+	smartctlFormat  = "smartctl"
+)
+
+var (
+	errUnsupportedFormat = errors.New("unsupported inventory format")
+	errEmptyInventory    = errors.New("empty inventory")
+)
+
+// inventorySubmission is an inventory submitted in one of the accepted formats.
+type inventorySubmission interface {
+	// format labels the submission in metrics and traces
+	format() string
+	convert(name, facility string) (*rivets.Server, error)
+}
+
+// alloySubmission is an inventory collected by Alloy.
+type alloySubmission struct {
+	dev *types.InventoryDevice
+}
+
+func (alloySubmission) format() string { return alloyFormat }
+
+func (s alloySubmission) convert(name, facility string) (*rivets.Server, error) {
+	return iconv.ToRivetsServer(name, facility, s.dev.Inv, s.dev.BiosCfg), nil
+}
+
+// redfishSubmission is a dump of the Redfish resources of a server.
+type redfishSubmission struct {
+	dump *redfishconverter.Dump
+}
+
+func (redfishSubmission) format() string { return redfishFormat }
+
+func (s redfishSubmission) convert(name, facility string) (*rivets.Server, error) {
+	return redfishconverter.ToRivetsServer(name, facility, s.dump)
+}
+
+// partialSubmission is implemented by formats describing only some of the
+// components of a server.
+type partialSubmission interface {
+	// covers reports whether the submission describes all the components of a slug
+	covers(slug string) bool
+	// merges reports whether the submission describes only some of the
+	// components of a slug, to be merged into the existing ones by serial
+	merges(slug string) bool
+}
+
+// toolSubmission is the output of a tool run on the server.
+type toolSubmission struct {
+	name   string
+	device *common.Device
+	// the slugs of the components the tool reports on
+	slugs []string
+	// the tool reports on single devices rather than all of those with its slugs
+	perDevice bool
+}
+
+func (s toolSubmission) format() string { return s.name }
+
+func (s toolSubmission) convert(name, facility string) (*rivets.Server, error) {
+	return iconv.ToRivetsServer(name, facility, s.device, nil), nil
+}
+
+func (s toolSubmission) covers(slug string) bool {
+	return !s.perDevice && s.reports(slug)
+}
+
+func (s toolSubmission) merges(slug string) bool {
+	return s.perDevice && s.reports(slug)
+}
+
+func (s toolSubmission) reports(slug string) bool {
+	for _, reported := range s.slugs {
+		if strings.EqualFold(reported, slug) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolFormats are the tool outputs accepted, by content type.
+var toolFormats = map[string]struct {
+	name      string
+	convert   func([]byte) (*common.Device, error)
+	slugs     []string
+	perDevice bool
+}{
+	constants.LshwContentType:      {lshwFormat, toolconverter.FromLshw, toolconverter.LshwSlugs, false},
+	constants.DmidecodeContentType: {dmidecodeFormat, toolconverter.FromDmidecode, toolconverter.DmidecodeSlugs, false},
+	constants.SmartctlContentType:  {smartctlFormat, toolconverter.FromSmartctl, toolconverter.SmartctlSlugs, true},
+}
+
+// decodeInventory decodes a submitted inventory in the format named by the
+// Content-Type of the request, Alloy's when there is none.
+func decodeInventory(ctx *gin.Context) (inventorySubmission, error) {
+	switch ctx.ContentType() {
+	case "", binding.MIMEJSON:
+		dev := &types.InventoryDevice{}
+		if err := ctx.ShouldBindJSON(dev); err != nil {
+			return nil, err
+		}
+
+		if dev.Inv == nil {
+			return nil, errEmptyInventory
+		}
+		return alloySubmission{dev: dev}, nil
+
+	case constants.RedfishContentType:
+		dump := &redfishconverter.Dump{}
+		if err := ctx.ShouldBindJSON(dump); err != nil {
+			return nil, err
+		}
+
+		if len(dump.Systems) == 0 {
+			return nil, errEmptyInventory
+		}
+		return redfishSubmission{dump: dump}, nil
+	}
+
+	if tool, ok := toolFormats[ctx.ContentType()]; ok {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		device, err := convertTool(tool.convert, body)
+		if err != nil {
+			if errors.Is(err, toolconverter.ErrNoData) {
+				return nil, errEmptyInventory
+			}
+			return nil, err
+		}
+		return toolSubmission{name: tool.name, device: device, slugs: tool.slugs, perDevice: tool.perDevice}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errUnsupportedFormat, ctx.ContentType())
+}
+
+// convertTool runs a tool output converter, turning any panic on malformed
+// output into an error.
+func convertTool(convert func([]byte) (*common.Device, error), body []byte) (device *common.Device, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errConversion, r)
+		}
+	}()
+
+	return convert(body)
+}
+
+// keepUncovered adds to latest the existing components a partial submission
+// doesn't report on, along with the server properties it lacks.
+func keepUncovered(partial partialSubmission, existing, latest *rivets.Server) {
+	submitted := map[string]struct{}{}
+	for _, c := range latest.Components {
+		if partial.merges(c.Name) {
+			submitted[componentkey.Of(c)] = struct{}{}
+		}
+	}
+
+	for _, c := range existing.Components {
+		if partial.covers(c.Name) {
+			continue
+		}
+		// the submitted one replaces it, the merge policies apply as usual
+		if _, ok := submitted[componentkey.Of(c)]; ok && partial.merges(c.Name) {
+			continue
+		}
+		latest.Components = append(latest.Components, c)
+	}
+
+	if latest.Vendor == "" {
+		latest.Vendor = existing.Vendor
+	}
+	if latest.Model == "" {
+		latest.Model = existing.Model
+	}
+	if latest.Serial == "" {
+		latest.Serial = existing.Serial
+	}
+	if latest.BIOSCfg == nil {
+		latest.BIOSCfg = existing.BIOSCfg
+	}
+}
+
+// rejectSubmission responds to an inventory that could not be decoded.
+func rejectSubmission(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errUnsupportedFormat):
+		reject(ctx, http.StatusUnsupportedMediaType, "unsupported inventory format", err.Error())
+	case errors.Is(err, errEmptyInventory):
+		reject(ctx, http.StatusBadRequest, "empty inventory", "")
+	default:
+		reject(ctx, http.StatusBadRequest, "invalid server inventory", err.Error())
+	}
+}
+
+// convertInventory converts a submitted inventory into a rivets.Server, turning
+// any panic on malformed input into an error.
+func convertInventory(ctx context.Context, name, facility string, sub inventorySubmission) (srv *rivets.Server, err error) {
+	_, span := tracer.Start(ctx, "inventory.convert", trace.WithAttributes(attribute.String("inventory.format", sub.format())))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errConversion, r)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int("component.count", len(srv.Components)))
+		}
+		span.End()
+	}()
+
+	return sub.convert(name, facility)
+}
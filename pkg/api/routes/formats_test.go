@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeInventory(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		format      string
+		err         error
+	}{
+		{
+			name:   "alloy without a content type",
+			body:   `{"inventory": {"vendor": "Dell Inc."}}`,
+			format: alloyFormat,
+		},
+		{
+			name:        "alloy",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"inventory": {"vendor": "Dell Inc."}}`,
+			format:      alloyFormat,
+		},
+		{
+			name:        "empty alloy inventory",
+			contentType: "application/json",
+			body:        `{}`,
+			err:         errEmptyInventory,
+		},
+		{
+			name:        "redfish",
+			contentType: constants.RedfishContentType,
+			body:        `{"Systems": [{"Id": "System.Embedded.1", "Manufacturer": "Dell Inc."}]}`,
+			format:      redfishFormat,
+		},
+		{
+			name:        "redfish without systems",
+			contentType: constants.RedfishContentType,
+			body:        `{"Managers": []}`,
+			err:         errEmptyInventory,
+		},
+		{
+			name:        "lshw",
+			contentType: constants.LshwContentType,
+			body:        `{"id": "host", "class": "system", "vendor": "Dell Inc."}`,
+			format:      lshwFormat,
+		},
+		{
+			name:        "lshw with null nodes",
+			contentType: constants.LshwContentType,
+			body:        `[{"id": "host", "class": "system", "vendor": "Dell Inc.", "children": [null, {"id": "core", "class": "bus", "children": [null]}]}]`,
+			format:      lshwFormat,
+		},
+		{
+			name:        "dmidecode",
+			contentType: constants.DmidecodeContentType + "; charset=utf-8",
+			body:        "Handle 0x0001, DMI type 1, 27 bytes\nSystem Information\n\tManufacturer: Dell Inc.\n",
+			format:      dmidecodeFormat,
+		},
+		{
+			name:        "smartctl without drives",
+			contentType: constants.SmartctlContentType,
+			body:        `[]`,
+			err:         errEmptyInventory,
+		},
+		{
+			name:        "unsupported",
+			contentType: "application/xml",
+			body:        `<inventory/>`,
+			err:         errUnsupportedFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.body))
+			if tc.contentType != "" {
+				ctx.Request.Header.Set("Content-Type", tc.contentType)
+			}
+
+			sub, err := decodeInventory(ctx)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.format, sub.format())
+
+			srv, err := convertInventory(ctx, "name", "facility", sub)
+			require.NoError(t, err)
+			require.Equal(t, "Dell Inc.", srv.Vendor)
+		})
+	}
+}
+
+func TestKeepUncovered(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{
+		Vendor:  "Dell Inc.",
+		BIOSCfg: map[string]string{"boot_mode": "uefi"},
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "old"},
+			{Name: common.SlugBMC, Serial: "0"},
+		},
+	}
+	latest := &rivets.Server{
+		Components: []*rivets.Component{{Name: common.SlugDrive, Serial: "new"}},
+	}
+
+	sub := toolSubmission{name: lshwFormat, slugs: []string{common.SlugDrive}}
+	keepUncovered(sub, existing, latest)
+
+	require.Len(t, latest.Components, 2)
+	require.Equal(t, "new", latest.Components[0].Serial)
+	require.Equal(t, common.SlugBMC, latest.Components[1].Name)
+	require.Equal(t, "Dell Inc.", latest.Vendor)
+	require.Equal(t, existing.BIOSCfg, latest.BIOSCfg)
+}
+
+func TestKeepUncoveredPerDevice(t *testing.T) {
+	t.Parallel()
+	existing := &rivets.Server{
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "d1"},
+			{Name: common.SlugDrive, Serial: "d2", Firmware: &common.Firmware{Installed: "1.0"}},
+			{Name: common.SlugDrive, Serial: "d3"},
+			{Name: common.SlugBMC, Serial: "0"},
+		},
+	}
+	latest := &rivets.Server{
+		Components: []*rivets.Component{
+			{Name: common.SlugDrive, Serial: "d2", Firmware: &common.Firmware{Installed: "2.0"}},
+			{Name: common.SlugDrive, Serial: "d4"},
+		},
+	}
+
+	sub := toolSubmission{name: smartctlFormat, slugs: []string{common.SlugDrive}, perDevice: true}
+	keepUncovered(sub, existing, latest)
+
+	// the drives smartctl wasn't run on stay, the one it was run on is replaced
+	require.ElementsMatch(t, []string{"d1", "d2", "d3", "d4", "0"}, serials(latest.Components))
+	require.Equal(t, "2.0", latest.Components[0].Firmware.Installed)
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	"go.uber.org/zap"
+)
+
+// composeHealthHandler rolls the status of a server's components up into a
+// single summary so an operator can see what is broken with one call.
+func composeHealthHandler(theApp *app.App) gin.HandlerFunc {
+	fdb := theApp.FleetDB
+	return func(ctx *gin.Context) {
+		logger := requestLogger(ctx, theApp.Log)
+		serverID, err := uuid.Parse(ctx.Param("server"))
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+			return
+		}
+
+		existing, err := getCachedServerInventory(ctx.Request.Context(), theApp.InventoryCache, fdb, serverID, inbandFromQuery(ctx))
+		if err != nil {
+			logger.With(
+				zap.Error(err),
+				zap.String("server.id", serverID.String()),
+			).Warn("server lookup")
+			reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "components unavailable", err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, health.Summarize(serverID.String(), existing.Components))
+	}
+}
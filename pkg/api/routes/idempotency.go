@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/internal/idempotency"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"go.uber.org/zap"
+)
+
+const (
+	// set on responses that were replayed for a repeated idempotency key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// replayedHeaders are the response headers recorded with a response, so that a
+// replay is the same as the original.
+var replayedHeaders = []string{
+	constants.ETagHeader,
+	constants.RequestIDHeader,
+}
+
+// responseRecorder keeps a copy of the response body written by a handler.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// composeIdempotency makes requests carrying an Idempotency-Key header safe to
+// repeat: the first request with a key is processed and its response recorded,
+// repeats of it get the recorded response without being processed again. Keys
+// are scoped to the caller, see callerKey, and to the request path, so the same
+// key may be used for different servers or by different callers. Only final outcomes are recorded, see recordable; conflicts, failed
+// preconditions, rate limits and server failures can be retried.
+func composeIdempotency(store *idempotency.Store, l *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(constants.IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			reject(ctx, http.StatusBadRequest, "invalid idempotency key", "key is too long")
+			ctx.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "unable to read request body", err.Error())
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		fingerprint := ctx.Request.URL.RawQuery + ":" + hex.EncodeToString(sum[:])
+		storeKey := callerKey(ctx) + ":" + ctx.Request.URL.Path + ":" + key
+
+		state, resp := store.Begin(storeKey, fingerprint)
+		switch state {
+		case idempotency.InFlight:
+			reject(ctx, http.StatusConflict, "a request with this idempotency key is in progress", "")
+			ctx.Abort()
+			return
+		case idempotency.Mismatch:
+			reject(ctx, http.StatusUnprocessableEntity, "idempotency key was used for a different request", "")
+			ctx.Abort()
+			return
+		case idempotency.Done:
+			requestLogger(ctx, l).With(
+				zap.String("idempotency.key", key),
+			).Debug("replaying response")
+			for name, values := range resp.Header {
+				ctx.Writer.Header()[name] = append([]string(nil), values...)
+			}
+			ctx.Header(idempotentReplayedHeader, "true")
+			if len(resp.Body) == 0 {
+				ctx.Status(resp.StatusCode)
+			} else {
+				ctx.Data(resp.StatusCode, resp.ContentType, resp.Body)
+			}
+			ctx.Abort()
+			return
+		}
+
+		// release the key if the handler panics so the request can be retried
+		recorded := false
+		defer func() {
+			if !recorded {
+				store.Abandon(storeKey)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+
+		ctx.Next()
+
+		code := recorder.Status()
+		if !recordable(code) {
+			return
+		}
+
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if values := recorder.Header().Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+
+		recorded = true
+		store.Complete(storeKey, &idempotency.Response{
+			StatusCode:  code,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Header:      header,
+			Body:        recorder.body.Bytes(),
+		})
+	}
+}
+
+// recordable reports whether a response is the final outcome of a request,
+// the same whenever it is repeated: a success, or a rejection of the request
+// itself rather than of the state it was received in.
+func recordable(code int) bool {
+	switch code {
+	case http.StatusBadRequest,
+		http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType,
+		http.StatusUnprocessableEntity:
+		return true
+	}
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/internal/idempotency"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIdempotencyRecordsFinalOutcomes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		code     int
+		recorded bool
+	}{
+		{"created", http.StatusCreated, true},
+		{"ok", http.StatusOK, true},
+		{"invalid", http.StatusBadRequest, true},
+		{"too large", http.StatusRequestEntityTooLarge, true},
+		{"unsupported", http.StatusUnsupportedMediaType, true},
+		{"unprocessable", http.StatusUnprocessableEntity, true},
+		{"conflict", http.StatusConflict, false},
+		{"precondition failed", http.StatusPreconditionFailed, false},
+		{"rate limited", http.StatusTooManyRequests, false},
+		{"failure", http.StatusInternalServerError, false},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			g := gin.New()
+			g.POST("/", composeIdempotency(idempotency.NewStore(time.Hour, 0), zap.NewNop()), func(ctx *gin.Context) {
+				calls++
+				ctx.Status(tc.code)
+			})
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
+				req.Header.Set(constants.IdempotencyKeyHeader, "key")
+				g.ServeHTTP(w, req)
+				require.Equal(t, tc.code, w.Code)
+			}
+
+			if tc.recorded {
+				require.Equal(t, 1, calls, "the repeat should be replayed")
+			} else {
+				require.Equal(t, 2, calls, "the repeat should be processed")
+			}
+		})
+	}
+}
+
+func TestIdempotencyScopedToCaller(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	g := gin.New()
+	g.POST("/", composeIdempotency(idempotency.NewStore(time.Hour, 0), zap.NewNop()), func(ctx *gin.Context) {
+		calls++
+		ctx.String(http.StatusCreated, ctx.ClientIP())
+	})
+
+	post := func(remoteAddr, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(constants.IdempotencyKeyHeader, "key")
+		g.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, "192.0.2.1", post("192.0.2.1:1234", `{}`).Body.String())
+
+	// another caller with the same key neither gets the response nor a mismatch
+	w := post("192.0.2.2:1234", `{"other": true}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "192.0.2.2", w.Body.String())
+	require.Equal(t, 2, calls)
+
+	require.Equal(t, "192.0.2.1", post("192.0.2.1:1234", `{}`).Body.String())
+	require.Equal(t, 2, calls)
+}
+
+func TestIdempotencyReplaysHeaders(t *testing.T) {
+	t.Parallel()
+	g := gin.New()
+	g.POST("/",
+		composeRequestID(zap.NewNop()),
+		composeIdempotency(idempotency.NewStore(time.Hour, 0), zap.NewNop()),
+		func(ctx *gin.Context) {
+			ctx.Header(constants.ETagHeader, `W/"tag"`)
+			ctx.JSON(http.StatusCreated, gin.H{"ok": true})
+		})
+
+	post := func(requestID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
+		req.Header.Set(constants.IdempotencyKeyHeader, "key")
+		req.Header.Set(constants.RequestIDHeader, requestID)
+		g.ServeHTTP(w, req)
+		return w
+	}
+
+	original := post("first")
+	replay := post("second")
+	require.Equal(t, "true", replay.Header().Get(idempotentReplayedHeader))
+	require.Equal(t, original.Code, replay.Code)
+	require.Equal(t, original.Body.String(), replay.Body.String())
+	require.Equal(t, `W/"tag"`, replay.Header().Get(constants.ETagHeader))
+	require.Equal(t, "first", replay.Header().Get(constants.RequestIDHeader))
+}
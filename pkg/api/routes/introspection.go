@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeInfo describes a registered route and the scopes it requires.
+type routeInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Scopes lists the token scopes accepted by the route, a token needs any one
+	// of them. Routes without scopes don't require authentication.
+	Scopes []string `json:"scopes"`
+}
+
+// routeList is the body of route listings.
+type routeList struct {
+	// AuthEnabled is false when scopes are not enforced, as JWT auth is not configured
+	AuthEnabled bool        `json:"auth_enabled"`
+	Routes      []routeInfo `json:"routes"`
+}
+
+// router registers routes on a gin Engine, keeping track of the scopes each one
+// requires so that they can be audited.
+type router struct {
+	g      *gin.Engine
+	routes []routeInfo
+}
+
+// handle registers a route. Routes with scopes get the auth handler ahead of
+// the given handlers.
+func (r *router) handle(method, path string, scopes []string, handlers ...gin.HandlerFunc) {
+	if scopes == nil {
+		scopes = []string{}
+	} else {
+		handlers = append([]gin.HandlerFunc{composeAuthHandler(scopes)}, handlers...)
+	}
+
+	r.routes = append(r.routes, routeInfo{
+		Method: method,
+		Path:   path,
+		Scopes: scopes,
+	})
+	r.g.Handle(method, path, handlers...)
+}
+
+// composeRoutesHandler lists the registered routes ordered by path and method.
+// Scopes are only enforced when JWT auth is configured, which is reported too.
+func composeRoutesHandler(r *router) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		routes := make([]routeInfo, len(r.routes))
+		copy(routes, r.routes)
+		sort.Slice(routes, func(i, j int) bool {
+			if routes[i].Path != routes[j].Path {
+				return routes[i].Path < routes[j].Path
+			}
+			return routes[i].Method < routes[j].Method
+		})
+
+		ctx.JSON(http.StatusOK, &routeList{
+			AuthEnabled: authMiddleWare != nil,
+			Routes:      routes,
+		})
+	}
+}
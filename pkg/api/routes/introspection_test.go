@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestHandler composes the API without dependencies. Tests using it are not
+// parallel, composing the API sets the global gin mode.
+func newTestHandler(developerMode bool) http.Handler {
+	theApp := &app.App{
+		Log: zap.NewNop(),
+		Cfg: &app.Configuration{
+			DeveloperMode:     developerMode,
+			IdempotencyWindow: time.Hour,
+			MetricsOpts:       app.MetricsOptions{ServeOnAPI: developerMode},
+			RateLimitOpts:     app.RateLimitOptions{Disabled: true},
+			BodyLimitOpts:     app.DefaultBodyLimits,
+		},
+	}
+	return ComposeHTTPServer(theApp).Handler
+}
+
+func registeredRoutes(t *testing.T, h http.Handler) []routeInfo {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.RoutesEndpoint, http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body routeList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Routes
+}
+
+func TestRoutesIntrospection(t *testing.T) {
+	listRoutes := func(developerMode bool) map[string]routeInfo {
+		routes := map[string]routeInfo{}
+		for _, r := range registeredRoutes(t, newTestHandler(developerMode)) {
+			routes[r.Method+" "+r.Path] = r
+		}
+		return routes
+	}
+
+	routes := listRoutes(false)
+	require.Equal(t, updateScopes("server:component"), routes["POST "+constants.InventoryEndpoint+"/:server"].Scopes)
+	require.Equal(t, readScopes("server:component"), routes["GET "+constants.ComponentsEndpoint+"/:server"].Scopes)
+	require.Empty(t, routes["GET "+constants.LivenessEndpoint].Scopes)
+	require.NotContains(t, routes, "POST "+constants.DiagnosticsEndpoint+"/echo")
+
+	routes = listRoutes(true)
+	require.Equal(t, createScopes("response"), routes["POST "+constants.DiagnosticsEndpoint+"/echo"].Scopes)
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+// biosInventory is an Alloy inband inventory with a BIOS configuration.
+const biosInventory = `{
+	"inventory": {
+		"vendor": "Dell Inc.",
+		"model": "PowerEdge R6515",
+		"serial": "ABC1234",
+		"drives": [{"serial": "d1", "vendor": "Samsung", "model": "MZ7LH960"}]
+	},
+	"biosconfig": {"boot_mode": "uefi", "smt": "enabled"}
+}`
+
+func submitInventory(h http.Handler, serverID uuid.UUID, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, constants.InventoryEndpoint+"/"+serverID.String()+"?mode=inband", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestInventoryUnchangedWithBIOSConfig(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := uuid.New()
+	fake.addServer(serverID)
+
+	w := submitInventory(h, serverID, biosInventory)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Equal(t, 1, fake.inventoryWrites)
+
+	// FleetDB doesn't return the BIOS configuration, the repeat is still unchanged
+	w = submitInventory(h, serverID, biosInventory)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "unchanged")
+	require.Equal(t, 1, fake.inventoryWrites)
+}
+
+func TestInventoryInvalidatesBothModes(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d0")
+	path := constants.ComponentsEndpoint + "/" + serverID.String() + "?mode=outofband"
+
+	// cache the out-of-band inventory
+	w := serveRequest(h, http.MethodGet, path)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = submitInventory(h, serverID, biosInventory)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// the inband submission added a record the out-of-band inventory lists too
+	w = serveRequest(h, http.MethodGet, path)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	components := []*rivets.Component{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &components))
+	require.ElementsMatch(t, []string{"d0", "d1"}, serials(components))
+}
+
+func TestComponentsETagNegotiated(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d1")
+	path := constants.ComponentsEndpoint + "/" + serverID.String()
+
+	get := func(accept, encoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+		req.Header.Set("Accept", accept)
+		req.Header.Set(constants.AcceptEncodingHeader, encoding)
+		req.Header.Set(constants.IfNoneMatchHeader, ifNoneMatch)
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	jsonTag := get("application/json", "", "").Header().Get(constants.ETagHeader)
+	csvTag := get(constants.CSVContentType, "", "").Header().Get(constants.ETagHeader)
+	require.NotEqual(t, jsonTag, csvTag)
+
+	// compressed or not, the representation is the same
+	require.Equal(t, jsonTag, get("application/json", "gzip", "").Header().Get(constants.ETagHeader))
+
+	require.Equal(t, http.StatusNotModified, get("application/json", "gzip", jsonTag).Code)
+	require.Equal(t, http.StatusOK, get(constants.CSVContentType, "", jsonTag).Code)
+}
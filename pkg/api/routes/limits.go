@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/metal-toolbox/component-inventory/internal/jsonlimit"
+)
+
+// composeBodyLimits bounds the request body of a route to maxBytes, after
+// decompression for compressed ones, and the structure of JSON bodies to the
+// given limits. The body is read in full here, so the handlers after it don't
+// read more than that.
+func composeBodyLimits(maxBytes int64, limits jsonlimit.Limits) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tooLarge := fmt.Sprintf("request bodies are limited to %d bytes", maxBytes)
+		if ctx.Request.ContentLength > maxBytes {
+			reject(ctx, http.StatusRequestEntityTooLarge, "request body too large", tooLarge)
+			ctx.Abort()
+			return
+		}
+
+		original := ctx.Request.Body
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, original, maxBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				reject(ctx, http.StatusRequestEntityTooLarge, "request body too large", tooLarge)
+			} else {
+				reject(ctx, http.StatusBadRequest, "unable to read request body", err.Error())
+			}
+			ctx.Abort()
+			return
+		}
+
+		body, ok := decompressBody(ctx, body, maxBytes)
+		if !ok {
+			ctx.Abort()
+			return
+		}
+
+		if isJSON(ctx.ContentType()) {
+			if err := limits.Check(body); err != nil {
+				reject(ctx, http.StatusBadRequest, "request body exceeds the JSON limits", err.Error())
+				ctx.Abort()
+				return
+			}
+		}
+
+		ctx.Request.Body = readCloser{Reader: bytes.NewReader(body), Closer: original}
+		ctx.Request.ContentLength = int64(len(body))
+	}
+}
+
+// readCloser reads a body that was read ahead and closes the original one.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// isJSON reports whether a content type is JSON, which bodies without one
+// are taken to be.
+func isJSON(contentType string) bool {
+	return contentType == "" || contentType == binding.MIMEJSON || strings.HasSuffix(contentType, "+json")
+}
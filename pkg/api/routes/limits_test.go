@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/internal/jsonlimit"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+)
+
+// chunked hides the size of a body from the server, as a chunked request does.
+type chunked struct{ io.Reader }
+
+func TestBodyLimits(t *testing.T) {
+	t.Parallel()
+	const maxBytes = 1024
+	limits := jsonlimit.Limits{MaxDepth: 8, MaxElements: 16}
+
+	body := []byte(`{"inventory": {"vendor": "Dell Inc."}}`)
+	compress := func(encoding string, data []byte) []byte {
+		compressed, err := compression.Compress(encoding, data)
+		require.NoError(t, err)
+		return compressed
+	}
+
+	// a broken collector stuffing metadata, and payloads crafted to exhaust memory
+	metadata := `{"inventory": {"drives": [{"metadata": {` + strings.Repeat(`"k": "v", `, 20) + `"k": "v"}}]}}`
+	zeros := make([]byte, 64<<20)
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		hideSize    bool
+		code        int
+	}{
+		{name: "within limits", body: body, code: http.StatusOK},
+		{name: "gzip", encoding: "gzip", body: compress(compression.Gzip, body), code: http.StatusOK},
+		{name: "zstd", encoding: "ZSTD", body: compress(compression.Zstd, body), code: http.StatusOK},
+		{name: "too large", body: bytes.Repeat([]byte(" "), maxBytes+1), code: http.StatusRequestEntityTooLarge},
+		{name: "too large without a size", body: bytes.Repeat([]byte(" "), maxBytes+1), hideSize: true, code: http.StatusRequestEntityTooLarge},
+		{name: "gzip bomb", encoding: "gzip", body: compress(compression.Gzip, zeros), code: http.StatusRequestEntityTooLarge},
+		{name: "zstd bomb", encoding: "zstd", body: compress(compression.Zstd, zeros), code: http.StatusRequestEntityTooLarge},
+		{name: "corrupt", encoding: "zstd", body: body, code: http.StatusBadRequest},
+		{name: "unsupported encoding", encoding: "br", body: body, code: http.StatusUnsupportedMediaType},
+		{name: "huge metadata map", contentType: "application/json", body: []byte(metadata), code: http.StatusBadRequest},
+		{name: "deep nesting", contentType: constants.RedfishContentType, body: []byte(strings.Repeat("[", 9)), code: http.StatusBadRequest},
+		{name: "deep nesting compressed", encoding: "gzip", body: compress(compression.Gzip, []byte(strings.Repeat("[", 9))), code: http.StatusBadRequest},
+		{name: "not JSON", contentType: constants.DmidecodeContentType, body: []byte(strings.Repeat("[", 9)), code: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g := gin.New()
+			g.POST("/", composeBodyLimits(maxBytes, limits), func(ctx *gin.Context) {
+				got, err := io.ReadAll(ctx.Request.Body)
+				if err != nil || int64(len(got)) != ctx.Request.ContentLength || ctx.GetHeader(constants.ContentEncodingHeader) != "" {
+					ctx.Status(http.StatusTeapot)
+					return
+				}
+				ctx.Status(http.StatusOK)
+			})
+
+			var reader io.Reader = bytes.NewReader(tc.body)
+			if tc.hideSize {
+				reader = chunked{reader}
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", reader)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.encoding != "" {
+				req.Header.Set(constants.ContentEncodingHeader, tc.encoding)
+			}
+
+			rec := httptest.NewRecorder()
+			g.ServeHTTP(rec, req)
+			require.Equal(t, tc.code, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// not parallel, see newTestHandler
+func TestInventoryBodyLimits(t *testing.T) {
+	h := newTestHandler(false)
+	path := constants.InventoryEndpoint + "/" + uuid.NewString()
+
+	// expands past the inventory limit, without reaching the handlers
+	bomb, err := compression.Compress(compression.Zstd, make([]byte, 2*app.DefaultBodyLimits.Inventory))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bomb))
+	req.Header.Set(constants.ContentEncodingHeader, compression.Zstd)
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	deep := `{"inventory": {"vendor": "Dell Inc.", "drives": ` + strings.Repeat("[", app.DefaultBodyLimits.MaxJSONDepth) + `}}`
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(deep)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), jsonlimit.ErrTooDeep.Error())
+}
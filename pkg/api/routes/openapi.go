@@ -0,0 +1,625 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/metal-toolbox/alloy/types"
+	"github.com/metal-toolbox/component-inventory/internal/metrics"
+	"github.com/metal-toolbox/component-inventory/internal/readiness"
+	"github.com/metal-toolbox/component-inventory/internal/redfishconverter"
+	"github.com/metal-toolbox/component-inventory/internal/toolconverter"
+	"github.com/metal-toolbox/component-inventory/internal/version"
+	"github.com/metal-toolbox/component-inventory/pkg/api/compression"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	"github.com/metal-toolbox/component-inventory/pkg/api/overrides"
+	rivets "github.com/metal-toolbox/rivets/types"
+)
+
+const (
+	// the security scheme of routes that require a JWT
+	jwtSecurityScheme = "jwt"
+	// the operation extension listing the scopes a route accepts, a token needs
+	// any one of them
+	scopesExtension = "x-scopes"
+
+	// the schema of inventory submissions, used to validate them
+	inventorySchema = "InventoryDevice"
+	// the schema of overrides, used to validate them
+	overridesSchema = "Overrides"
+)
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Message   string `json:"message"`
+	Err       string `json:"err"`
+	RequestID string `json:"request_id"`
+}
+
+// readinessResponse is the body of readiness responses.
+type readinessResponse struct {
+	Time         time.Time                              `json:"time"`
+	Dependencies map[string]*readiness.DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// textResponse stands for a plain text response body in the document.
+type textResponse struct{}
+
+// binaryResponse stands for a binary response body in the document.
+type binaryResponse struct{}
+
+// messageResponse is the body of responses that only carry a message.
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// apiOperation describes a route of the API for the OpenAPI document.
+type apiOperation struct {
+	method  string
+	path    string
+	summary string
+	scopes  []string
+	params  []*openapi3.Parameter
+	// name and a value of the type of the request body, if any
+	bodyName string
+	body     any
+	// other content types the request body may be in
+	altBodies []altBody
+	// success status and a value of the type of its body, nil for no body
+	status   int
+	response any
+	// other content types the success response may be in
+	altResponses []altBody
+	// other success responses, by status
+	also map[int]any
+	// error statuses the operation may respond with
+	errors []int
+}
+
+// altBody is a request or response body in another content type than JSON.
+type altBody struct {
+	contentType string
+	// name and a value of the type of the body, textResponse for plain text
+	// and binaryResponse for binary data
+	name  string
+	value any
+}
+
+var (
+	serverParam = openapi3.NewPathParameter("server").
+			WithDescription("the server id").
+			WithSchema(openapi3.NewUUIDSchema())
+	modeParam = openapi3.NewQueryParameter("mode").
+			WithDescription("the inventory to use, inband unless outofband is asked for").
+			WithSchema(openapi3.NewStringSchema().WithEnum(constants.InBandMode, constants.OutOfBandMode))
+	contentEncodingParam = openapi3.NewHeaderParameter(constants.ContentEncodingHeader).
+				WithDescription("the compression of the request body, if any").
+				WithSchema(openapi3.NewStringSchema().WithEnum(compression.Gzip, compression.Zstd, compression.Identity))
+	requestIDParam = openapi3.NewHeaderParameter(constants.RequestIDHeader).
+			WithDescription("correlates the request in logs and responses, generated when missing").
+			WithSchema(openapi3.NewStringSchema().WithMaxLength(maxRequestIDLength))
+)
+
+// apiOperations lists every route of the API. It must be kept in step with the
+// routes registered in ComposeHTTPServer.
+func apiOperations() []apiOperation {
+	return []apiOperation{
+		{
+			method:   http.MethodGet,
+			path:     constants.LivenessEndpoint,
+			summary:  "report that the service is running",
+			status:   http.StatusOK,
+			response: &readinessResponse{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.ReadinessEndpoint,
+			summary:  "report whether the dependencies of the service are usable",
+			status:   http.StatusOK,
+			response: &readinessResponse{},
+			errors:   []int{http.StatusServiceUnavailable},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.VersionEndpoint,
+			summary:  "report the version of the service",
+			status:   http.StatusOK,
+			response: version.Current(),
+		},
+		{
+			method:   http.MethodGet,
+			path:     metrics.Endpoint,
+			summary:  "expose prometheus metrics, when configured to be served on the API listener",
+			status:   http.StatusOK,
+			response: textResponse{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     constants.DiagnosticsEndpoint + "/echo",
+			summary:  "respond with the request body, in developer mode only",
+			scopes:   createScopes("response"),
+			params:   []*openapi3.Parameter{contentEncodingParam},
+			bodyName: "Echo",
+			body:     map[string]any{},
+			status:   http.StatusOK,
+			response: map[string]any{},
+			errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType},
+		},
+		{
+			method:   http.MethodPost,
+			path:     constants.DiagnosticsEndpoint + "/error",
+			summary:  "respond with an error, in developer mode only",
+			scopes:   createScopes("response"),
+			params:   []*openapi3.Parameter{contentEncodingParam},
+			bodyName: "Echo",
+			body:     map[string]any{},
+			status:   http.StatusOK,
+			response: map[string]any{},
+			errors:   []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusInternalServerError},
+		},
+		{
+			method:  http.MethodGet,
+			path:    constants.ComponentsEndpoint + "/:server",
+			summary: "get the components of a server as JSON, CSV, YAML or protobuf; the weak ETag changes with the components and the content type, and If-None-Match is honored",
+			scopes:  readScopes("server:component"),
+			params: []*openapi3.Parameter{
+				serverParam,
+				modeParam,
+				openapi3.NewHeaderParameter(constants.IfNoneMatchHeader).
+					WithDescription("the ETag of components the caller has").
+					WithSchema(openapi3.NewStringSchema()),
+			},
+			status:   http.StatusOK,
+			response: []*rivets.Component{},
+			altResponses: []altBody{
+				{contentType: constants.CSVContentType, value: textResponse{}},
+				{contentType: constants.YAMLContentType, value: []*rivets.Component{}},
+				{contentType: constants.ProtobufContentType, value: binaryResponse{}},
+			},
+			also: map[int]any{http.StatusNotModified: nil},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusNotAcceptable,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.ComponentsEndpoint + "/:server" + constants.ComponentHealthPath,
+			summary:  "get a health summary of the components of a server",
+			scopes:   readScopes("server:component"),
+			params:   []*openapi3.Parameter{serverParam, modeParam},
+			status:   http.StatusOK,
+			response: &health.ServerHealth{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method:  http.MethodGet,
+			path:    constants.ReportsEndpoint + constants.DegradedReportPath,
+			summary: "list the degraded components of the servers in a facility or of the given servers; the scan is limited to " + reportTimeout.String() + ", servers it didn't get to are listed as errors",
+			scopes:  readScopes("server:component"),
+			params: []*openapi3.Parameter{
+				openapi3.NewQueryParameter("facility").
+					WithDescription("report on every server in this facility").
+					WithSchema(openapi3.NewStringSchema()),
+				openapi3.NewQueryParameter("server").
+					WithDescription("report on these servers").
+					WithSchema(openapi3.NewArraySchema().WithItems(openapi3.NewUUIDSchema())),
+				modeParam,
+			},
+			status:   http.StatusOK,
+			response: &health.Report{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method:  http.MethodPost,
+			path:    constants.InventoryEndpoint + "/:server",
+			summary: "submit the inventory of a server",
+			scopes:  updateScopes("server:component"),
+			params: []*openapi3.Parameter{
+				serverParam,
+				modeParam,
+				openapi3.NewHeaderParameter(constants.IdempotencyKeyHeader).
+					WithDescription("makes the submission safe to retry").
+					WithSchema(openapi3.NewStringSchema().WithMaxLength(maxIdempotencyKeyLength)),
+				openapi3.NewHeaderParameter(constants.IfMatchHeader).
+					WithDescription("only update the components if their ETag, for any content type, is one of these").
+					WithSchema(openapi3.NewStringSchema()),
+				contentEncodingParam,
+			},
+			bodyName: inventorySchema,
+			body:     &types.InventoryDevice{},
+			altBodies: []altBody{
+				{contentType: constants.RedfishContentType, name: "RedfishDump", value: &redfishconverter.Dump{}},
+				{contentType: constants.LshwContentType, name: "LshwNode", value: &toolconverter.LshwNode{}},
+				{contentType: constants.DmidecodeContentType, value: textResponse{}},
+				{contentType: constants.SmartctlContentType, name: "Smartctl", value: &toolconverter.Smartctl{}},
+			},
+			status: http.StatusCreated,
+			also:   map[int]any{http.StatusOK: &messageResponse{}},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusConflict,
+				http.StatusPreconditionFailed,
+				http.StatusRequestEntityTooLarge,
+				http.StatusUnsupportedMediaType,
+				http.StatusUnprocessableEntity,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+				http.StatusServiceUnavailable,
+			},
+		},
+		{
+			method:   http.MethodDelete,
+			path:     constants.ComponentsEndpoint + "/:server",
+			summary:  "remove all the components of a server from the inventory of a mode",
+			scopes:   deleteScopes("server:component"),
+			params:   []*openapi3.Parameter{serverParam, modeParam},
+			status:   http.StatusOK,
+			response: &history.Entry{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusConflict,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+				http.StatusServiceUnavailable,
+			},
+		},
+		{
+			method:  http.MethodDelete,
+			path:    constants.ComponentsEndpoint + "/:server/:slug/:serial",
+			summary: "remove a component of a server from the inventory of a mode",
+			scopes:  deleteScopes("server:component"),
+			params: []*openapi3.Parameter{
+				serverParam,
+				openapi3.NewPathParameter("slug").
+					WithDescription("the component type, e.g. drive").
+					WithSchema(openapi3.NewStringSchema()),
+				openapi3.NewPathParameter("serial").
+					WithDescription("the component serial").
+					WithSchema(openapi3.NewStringSchema()),
+				modeParam,
+			},
+			status:   http.StatusOK,
+			response: &history.Entry{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusConflict,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+				http.StatusServiceUnavailable,
+			},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.ComponentsEndpoint + "/:server" + constants.OverridesPath,
+			summary:  "get the operator-maintained components and annotations of a server",
+			scopes:   readScopes("server:component"),
+			params:   []*openapi3.Parameter{serverParam},
+			status:   http.StatusOK,
+			response: &overrides.Set{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method:   http.MethodPut,
+			path:     constants.ComponentsEndpoint + "/:server" + constants.OverridesPath,
+			summary:  "replace the operator-maintained components and annotations of a server, kept across inventory submissions",
+			scopes:   updateScopes("server:component"),
+			params:   []*openapi3.Parameter{serverParam, contentEncodingParam},
+			bodyName: overridesSchema,
+			body:     &overrides.Set{},
+			status:   http.StatusOK,
+			response: &overrides.Set{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusConflict,
+				http.StatusRequestEntityTooLarge,
+				http.StatusUnsupportedMediaType,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+				http.StatusServiceUnavailable,
+			},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.ComponentsEndpoint + "/:server" + constants.HistoryPath,
+			summary:  "list the changes made to the inventory of a server through the API, most recent first",
+			scopes:   readScopes("server:component"),
+			params:   []*openapi3.Parameter{serverParam},
+			status:   http.StatusOK,
+			response: []*history.Entry{},
+			errors: []int{
+				http.StatusBadRequest,
+				http.StatusNotFound,
+				http.StatusTooManyRequests,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.RoutesEndpoint,
+			summary:  "list the routes of the API and the scopes they accept",
+			scopes:   readScopes("routes"),
+			status:   http.StatusOK,
+			response: &routeList{},
+			errors:   []int{http.StatusTooManyRequests},
+		},
+		{
+			method:   http.MethodGet,
+			path:     constants.OpenAPIEndpoint,
+			summary:  "get this document",
+			status:   http.StatusOK,
+			response: map[string]any{},
+		},
+	}
+}
+
+// openAPIDocument describes the API in OpenAPI 3. The schemas of request and
+// response bodies are generated from the Go types they are decoded into or
+// encoded from.
+func openAPIDocument() (*openapi3.T, error) {
+	// builds without version information are development builds
+	appVersion := version.Current().AppVersion
+	if appVersion == "" {
+		appVersion = "dev"
+	}
+
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "Component Inventory Service",
+			Version: appVersion,
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				jwtSecurityScheme: &openapi3.SecuritySchemeRef{
+					Value: openapi3.NewJWTSecurityScheme(),
+				},
+			},
+		},
+	}
+
+	gen := &schemaGenerator{doc: doc}
+	errorRef := gen.named("Error", &errorResponse{})
+
+	for _, op := range apiOperations() {
+		operation := openapi3.NewOperation()
+		operation.Summary = op.summary
+		operation.OperationID = operationID(op.method, op.path)
+		operation.AddParameter(requestIDParam)
+		for _, p := range op.params {
+			operation.AddParameter(p)
+		}
+
+		if op.scopes != nil {
+			operation.Security = openapi3.NewSecurityRequirements().With(
+				openapi3.NewSecurityRequirement().Authenticate(jwtSecurityScheme),
+			)
+			operation.Extensions = map[string]any{scopesExtension: op.scopes}
+		}
+
+		if op.body != nil {
+			body := openapi3.NewRequestBody().
+				WithRequired(true).
+				WithJSONSchemaRef(gen.named(op.bodyName, op.body))
+			for _, alt := range op.altBodies {
+				body.Content[alt.contentType] = openapi3.NewMediaType().WithSchemaRef(gen.altSchema(alt))
+			}
+			operation.RequestBody = &openapi3.RequestBodyRef{Value: body}
+		}
+
+		operation.Responses = openapi3.NewResponses()
+		successes := map[int]any{op.status: op.response}
+		for status, body := range op.also {
+			successes[status] = body
+		}
+		for status, body := range successes {
+			resp := openapi3.NewResponse().WithDescription(http.StatusText(status))
+			switch b := body.(type) {
+			case nil:
+			case textResponse:
+				resp.WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"}))
+			default:
+				resp.WithJSONSchemaRef(gen.inline(b))
+			}
+			if status == op.status {
+				for _, alt := range op.altResponses {
+					resp.Content[alt.contentType] = openapi3.NewMediaType().WithSchemaRef(gen.altSchema(alt))
+				}
+			}
+			operation.AddResponse(status, resp)
+		}
+
+		for _, status := range op.errors {
+			operation.AddResponse(status, openapi3.NewResponse().
+				WithDescription(http.StatusText(status)).
+				WithJSONSchemaRef(errorRef))
+		}
+
+		doc.AddOperation(openAPIPath(op.path), op.method, operation)
+	}
+
+	// an inventory is all a submission is for
+	if inventory, ok := doc.Components.Schemas[inventorySchema]; ok {
+		inventory.Value.Required = []string{"inventory"}
+	}
+
+	return doc, gen.err
+}
+
+// schemaGenerator generates the schemas of Go types, keeping the first error.
+type schemaGenerator struct {
+	doc *openapi3.T
+	err error
+}
+
+func (g *schemaGenerator) generate(v any) *openapi3.SchemaRef {
+	// types referring to themselves are added to the document components
+	ref, err := openapi3gen.NewSchemaRefForValue(v, g.doc.Components.Schemas,
+		openapi3gen.UseAllExportedFields(),
+		openapi3gen.SchemaCustomizer(nullableCollections),
+	)
+	if err != nil && g.err == nil {
+		g.err = err
+	}
+	return ref
+}
+
+// named adds the schema of v to the document components and refers to it.
+func (g *schemaGenerator) named(name string, v any) *openapi3.SchemaRef {
+	if existing, ok := g.doc.Components.Schemas[name]; ok {
+		return openapi3.NewSchemaRef("#/components/schemas/"+name, existing.Value)
+	}
+
+	ref := g.generate(v)
+	if ref == nil {
+		return openapi3.NewSchemaRef("", openapi3.NewObjectSchema())
+	}
+	g.doc.Components.Schemas[name] = ref
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, ref.Value)
+}
+
+// altSchema refers to the schema of a body in another content type than JSON,
+// inlined when it has no name.
+func (g *schemaGenerator) altSchema(alt altBody) *openapi3.SchemaRef {
+	switch alt.value.(type) {
+	case textResponse:
+		return openapi3.NewStringSchema().NewRef()
+	case binaryResponse:
+		return openapi3.NewBytesSchema().WithFormat("binary").NewRef()
+	}
+	if alt.name == "" {
+		return g.inline(alt.value)
+	}
+	return g.named(alt.name, alt.value)
+}
+
+func (g *schemaGenerator) inline(v any) *openapi3.SchemaRef {
+	if ref := g.generate(v); ref != nil {
+		return ref
+	}
+	return openapi3.NewSchemaRef("", openapi3.NewObjectSchema())
+}
+
+// nullableCollections allows null for maps and slices, as that is how Go
+// encodes them when they are nil.
+func nullableCollections(_ string, t reflect.Type, _ reflect.StructTag, schema *openapi3.Schema) error {
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice:
+		schema.Nullable = true
+	}
+	return nil
+}
+
+// openAPIPath turns the parameters of a gin path into OpenAPI parameters.
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + strings.TrimPrefix(part, ":") + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// operationID derives a unique id for an operation from its method and path.
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "-", ":", "", ".", "-", "_", "")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "-")
+}
+
+// composeOpenAPIHandler serves the OpenAPI document.
+func composeOpenAPIHandler(doc *openapi3.T) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, doc)
+	}
+}
+
+// composeBodyValidation rejects request bodies that don't match the named
+// schema of the OpenAPI document, listing every mismatch.
+func composeBodyValidation(doc *openapi3.T, name string) gin.HandlerFunc {
+	schema := doc.Components.Schemas[name].Value
+	return func(ctx *gin.Context) {
+		// the schema describes JSON bodies, handlers check the other formats
+		if ct := ctx.ContentType(); ct != "" && ct != binding.MIMEJSON {
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "unable to read request body", err.Error())
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid request body", err.Error())
+			ctx.Abort()
+			return
+		}
+
+		if err := schema.VisitJSON(value, openapi3.MultiErrors()); err != nil {
+			reject(ctx, http.StatusBadRequest, "request body does not match the "+name+" schema", validationErrors(err))
+			ctx.Abort()
+			return
+		}
+	}
+}
+
+// validationErrors lists schema mismatches one per line, by the location of the
+// offending value.
+func validationErrors(err error) string {
+	var errs openapi3.MultiError
+	if !errors.As(err, &errs) {
+		return err.Error()
+	}
+
+	lines := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if se, ok := e.(*openapi3.SchemaError); ok {
+			lines = append(lines, strconv.Quote("/"+strings.Join(se.JSONPointer(), "/"))+": "+se.Reason)
+			continue
+		}
+		lines = append(lines, e.Error())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,119 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPIDrift fails when the routes registered by ComposeHTTPServer, or the
+// scopes they accept, differ from the OpenAPI document.
+func TestOpenAPIDrift(t *testing.T) {
+	// developer mode registers every optional route
+	h := newTestHandler(true)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.OpenAPIEndpoint, http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	documented := map[string][]any{}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			scopes, _ := op.Extensions[scopesExtension].([]any)
+			documented[method+" "+path] = scopes
+		}
+	}
+
+	registered := map[string][]any{}
+	for _, r := range registeredRoutes(t, h) {
+		scopes := []any{}
+		for _, s := range r.Scopes {
+			scopes = append(scopes, s)
+		}
+		if len(scopes) == 0 {
+			scopes = nil
+		}
+		registered[r.Method+" "+openAPIPath(r.Path)] = scopes
+	}
+
+	require.Equal(t, registered, documented)
+}
+
+func TestBodyValidation(t *testing.T) {
+	t.Parallel()
+	doc, err := openAPIDocument()
+	require.NoError(t, err)
+
+	g := gin.New()
+	g.POST("/", composeBodyValidation(doc, inventorySchema), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name string
+		body string
+		code int
+		err  string
+	}{
+		{
+			name: "valid",
+			body: `{"inventory": {"vendor": "acme", "cpus": [{"cores": 32, "firmware": null}]}, "biosconfig": null}`,
+			code: http.StatusCreated,
+		},
+		{
+			name: "not json",
+			body: `{"inventory":`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "no inventory",
+			body: `{"biosconfig": {"boot_mode": "UEFI"}}`,
+			code: http.StatusBadRequest,
+			err:  `property "inventory" is missing`,
+		},
+		{
+			name: "wrong types",
+			body: `{"inventory": {"cpus": [{"cores": "many"}]}, "biosconfig": {"boot_mode": 1}}`,
+			code: http.StatusBadRequest,
+			err:  "\"/biosconfig/boot_mode\": value must be a string\n\"/inventory/cpus/0/cores\": value must be an integer",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.body)))
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.err != "" {
+				var resp errorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				require.Contains(t, resp.Err, tc.err)
+			}
+		})
+	}
+}
+
+// not parallel, see newTestHandler
+func TestInventoryValidation(t *testing.T) {
+	h := newTestHandler(false)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, constants.InventoryEndpoint+"/"+uuid.NewString(), bytes.NewBufferString(`{"inventory": []}`))
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/internal/fingerprint"
+	"github.com/metal-toolbox/component-inventory/pkg/api/history"
+	"github.com/metal-toolbox/component-inventory/pkg/api/overrides"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/zap"
+)
+
+// composeGetOverridesHandler returns the operator-maintained components and
+// annotations of a server.
+func composeGetOverridesHandler(theApp *app.App) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		serverID, err := uuid.Parse(ctx.Param("server"))
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+			return
+		}
+
+		reqCtx := ctx.Request.Context()
+		set, exists, err := getOverrides(reqCtx, theApp.FleetDB, serverID)
+		if err == nil && !exists {
+			// FleetDB doesn't tell a server without overrides from an unknown one
+			_, err = getServer(reqCtx, theApp.FleetDB, serverID)
+		}
+		if err != nil {
+			requestLogger(ctx, theApp.Log).With(
+				zap.Error(err),
+				zap.String("server.id", serverID.String()),
+			).Warn("overrides lookup")
+			reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "overrides unavailable", err.Error())
+			return
+		}
+
+		ctx.JSON(http.StatusOK, set)
+	}
+}
+
+// composeSetOverridesHandler replaces the operator-maintained components and
+// annotations of a server and adds the components to its stored inventories.
+//
+// FleetDB keeps components it isn't given, so components dropped from the
+// overrides stay in the inventory until they are deleted.
+func composeSetOverridesHandler(theApp *app.App) gin.HandlerFunc {
+	fdb := theApp.FleetDB
+	return func(ctx *gin.Context) {
+		logger := requestLogger(ctx, theApp.Log)
+
+		serverID, err := uuid.Parse(ctx.Param("server"))
+		if err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+			return
+		}
+		logger = logger.With(zap.String("server.id", serverID.String()))
+
+		set := &overrides.Set{}
+		if err := ctx.BindJSON(set); err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid overrides", err.Error())
+			return
+		}
+
+		if err := set.Validate(); err != nil {
+			reject(ctx, http.StatusBadRequest, "invalid overrides", err.Error())
+			return
+		}
+
+		reqCtx := ctx.Request.Context()
+		unlock, err := lockServer(reqCtx, theApp.ServerLock, serverID)
+		if err != nil {
+			rejectLockFailure(ctx, logger, err)
+			return
+		}
+		defer unlock()
+
+		previous, exists, err := getOverrides(reqCtx, fdb, serverID)
+		if err != nil {
+			logger.With(zap.Error(err)).Warn("overrides lookup")
+			reject(ctx, http.StatusInternalServerError, "unable to retrieve overrides", err.Error())
+			return
+		}
+
+		set.UpdatedAt = time.Now().UTC()
+		set.UpdatedBy = ginjwt.GetSubject(ctx)
+
+		// record the overrides being replaced first, they can't be recovered without it
+		if exists {
+			entry := &history.Entry{
+				Time:        set.UpdatedAt,
+				Action:      history.ActionSetOverrides,
+				Actor:       set.UpdatedBy,
+				RequestID:   requestID(ctx),
+				Components:  previous.Components,
+				Annotations: previous.Annotations,
+			}
+			if err := addHistory(reqCtx, fdb, serverID, entry); err != nil {
+				logger.With(zap.Error(err)).Warn("history update")
+				reject(ctx, http.StatusInternalServerError, "unable to record the change", err.Error())
+				return
+			}
+		}
+
+		if err := setOverrides(reqCtx, fdb, serverID, set, exists); err != nil {
+			logger.With(zap.Error(err)).Warn("overrides update")
+			reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "unable to store overrides", err.Error())
+			return
+		}
+
+		for _, inband := range []bool{true, false} {
+			err := applyOverrides(reqCtx, theApp, serverID, inband, set)
+			theApp.InventoryCache.Invalidate(serverID, inband)
+			if err != nil {
+				logger.With(zap.Error(err), zap.String("mode", modeString(inband))).Error("overrides application")
+				reject(ctx, http.StatusInternalServerError, "overrides stored, they are added to the inventory with its next submission", err.Error())
+				return
+			}
+		}
+
+		logger.With(
+			zap.Int("component.count", len(set.Components)),
+			zap.Int("annotation.count", len(set.Annotations)),
+		).Info("overrides updated")
+		ctx.JSON(http.StatusOK, set)
+	}
+}
+
+// applyOverrides adds the override components to the stored inventory of a
+// mode, if the server has one.
+func applyOverrides(ctx context.Context, theApp *app.App, serverID uuid.UUID, inband bool, set *overrides.Set) error {
+	srv, err := getServerInventory(ctx, theApp.FleetDB, serverID, inband)
+	if err != nil {
+		if fleetDBErrorStatus(err, http.StatusInternalServerError) == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	before := fingerprint.Server(srv)
+	set.Apply(srv)
+	if fingerprint.Server(srv) == before {
+		return nil
+	}
+
+	return setServerInventory(ctx, theApp.FleetDB, serverID, srv, inband)
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/overrides"
+	"github.com/stretchr/testify/require"
+)
+
+func overridesPath(serverID uuid.UUID) string {
+	return constants.ComponentsEndpoint + "/" + serverID.String() + constants.OverridesPath
+}
+
+func putOverrides(h http.Handler, serverID uuid.UUID, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, overridesPath(serverID), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestOverrides(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := uuid.New()
+	fake.addServer(serverID)
+
+	w := serveRequest(h, http.MethodGet, overridesPath(serverID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	set := &overrides.Set{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), set))
+	require.Empty(t, set.Components)
+
+	w = serveRequest(h, http.MethodGet, overridesPath(uuid.New()))
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = putOverrides(h, uuid.New(), `{"components": []}`)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = putOverrides(h, serverID, `{"components": [{"name": "Drive", "serial": "d1"}, {"name": "drive", "serial": "d1"}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = putOverrides(h, serverID, `{
+		"components": [{"name": "Drive", "serial": "d1", "vendor": "acme"}],
+		"annotations": {"asset_tag": "A123"}
+	}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = serveRequest(h, http.MethodGet, overridesPath(serverID))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	set = &overrides.Set{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), set))
+	require.Len(t, set.Components, 1)
+	require.Equal(t, "A123", set.Annotations["asset_tag"])
+
+	// the override wins over the collected component with the same serial
+	w = submitInventory(h, serverID, biosInventory)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	components := fake.components(serverID, true)
+	require.Len(t, components, 1)
+	require.Equal(t, common.SlugDrive, components[0].Name)
+	require.Equal(t, "acme", components[0].Vendor)
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/internal/metrics"
+	"github.com/metal-toolbox/component-inventory/internal/ratelimit"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// the rate limits applied to routes, as named in metrics
+const (
+	readRateLimit  = "read"
+	writeRateLimit = "write"
+)
+
+// composeRateLimit throttles clients making more requests than the limit allows.
+// Clients are identified by the subject of their JWT, so it has to run after the
+// auth handler, and by their IP address when auth is disabled.
+func composeRateLimit(cfg app.RateLimitOptions, name string) gin.HandlerFunc {
+	if cfg.Disabled {
+		return ginNoOp
+	}
+
+	limit := cfg.Read
+	if name == writeRateLimit {
+		limit = cfg.Write
+	}
+	limiter := ratelimit.NewLimiter(limit.RequestsPerSecond, limit.Burst)
+
+	return func(ctx *gin.Context) {
+		ok, retryAfter := limiter.Allow(callerKey(ctx))
+		if ok {
+			return
+		}
+
+		metrics.Throttled(name)
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		reject(ctx, http.StatusTooManyRequests, "rate limit exceeded", "")
+		ctx.Abort()
+	}
+}
+
+// callerKey identifies the caller of a request by the subject of their JWT, and
+// by their IP address when auth is disabled.
+func callerKey(ctx *gin.Context) string {
+	if subject := ginjwt.GetSubject(ctx); subject != "" {
+		return "sub:" + subject
+	}
+	return "ip:" + ctx.ClientIP()
+}
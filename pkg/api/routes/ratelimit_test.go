@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	cfg := app.RateLimitOptions{
+		Read:  app.RateLimit{RequestsPerSecond: 100, Burst: 100},
+		Write: app.RateLimit{RequestsPerSecond: 0.5, Burst: 1},
+	}
+
+	g := gin.New()
+	g.POST("/", composeRateLimit(cfg, writeRateLimit), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusCreated, post("10.0.0.1:1234").Code)
+
+	w := post("10.0.0.1:1234")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// other clients are not throttled
+	require.Equal(t, http.StatusCreated, post("10.0.0.2:1234").Code)
+}
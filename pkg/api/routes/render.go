@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/metal-toolbox/component-inventory/pkg/api/componentpb"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"gopkg.in/yaml.v3"
+)
+
+// componentsFormats are the content types components are served in, JSON
+// first as the default for requests without an Accept header.
+var componentsFormats = []string{
+	binding.MIMEJSON,
+	constants.CSVContentType,
+	constants.YAMLContentType,
+	binding.MIMEYAML,
+	constants.ProtobufContentType,
+	"application/protobuf",
+}
+
+var errNotAcceptable = errors.New("none of the accepted content types is available")
+
+// csvColumns are the header of components rendered as CSV.
+var csvColumns = []string{
+	"server", "slug", "vendor", "model", "serial", "firmware", "state", "health",
+	"slot", "part_number", "capacity_bytes", "size_bytes", "updated",
+}
+
+// negotiateComponentsFormat picks the content type to serve components in
+// from the Accept header of the request, or fails with errNotAcceptable.
+func negotiateComponentsFormat(ctx *gin.Context) (string, error) {
+	// the body depends on the Accept header, caches have to know
+	ctx.Writer.Header().Add("Vary", "Accept")
+
+	format := ctx.NegotiateFormat(componentsFormats...)
+	if format == "" {
+		return "", errNotAcceptable
+	}
+	return format, nil
+}
+
+// renderComponents responds with the components of a server in the given
+// content type, one of componentsFormats.
+func renderComponents(ctx *gin.Context, format, serverID string, components []*rivets.Component) error {
+	var body []byte
+	var err error
+
+	switch format {
+	case constants.CSVContentType:
+		body, err = componentsCSV(serverID, components)
+		format += "; charset=utf-8"
+	case constants.YAMLContentType, binding.MIMEYAML:
+		body, err = componentsYAML(components)
+		format += "; charset=utf-8"
+	case constants.ProtobufContentType, "application/protobuf":
+		body = componentpb.FromRivets(serverID, components).Marshal()
+	default:
+		ctx.JSON(http.StatusOK, components)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx.Data(http.StatusOK, format, body)
+	return nil
+}
+
+// componentsCSV flattens components into one row each. The values come from
+// collectors and BMCs, cells a spreadsheet would take for a formula are
+// neutralized, see csvCell.
+func componentsCSV(serverID string, components []*rivets.Component) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+
+	for _, c := range components {
+		row := []string{serverID, c.Name, c.Vendor, c.Model, c.Serial, "", "", "", "", "", "", "", ""}
+		if c.Firmware != nil {
+			row[5] = c.Firmware.Installed
+		}
+		if c.Status != nil {
+			row[6], row[7] = c.Status.State, c.Status.Health
+		}
+		if a := c.Attributes; a != nil {
+			row[8], row[9] = a.Slot, a.PartNumber
+			row[10], row[11] = formatBytes(a.CapacityBytes), formatBytes(a.SizeBytes)
+		}
+		if !c.UpdatedAt.IsZero() {
+			row[12] = c.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		for idx := range row {
+			row[idx] = csvCell(row[idx])
+		}
+
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvCell prefixes values spreadsheets would evaluate as a formula with a
+// quote, so that they are shown as text.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func formatBytes(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// componentsYAML renders components as YAML with the keys of their JSON
+// encoding, by way of that encoding; JSON is YAML and integers stay integers.
+func componentsYAML(components []*rivets.Component) ([]byte, error) {
+	data, err := json.Marshal(components)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(doc)
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/component-inventory/pkg/api/componentpb"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderComponents(t *testing.T) {
+	t.Parallel()
+	components := []*rivets.Component{
+		{
+			Name:      common.SlugDrive,
+			Vendor:    "Micron",
+			Model:     "MTFDDAK480TDS",
+			Serial:    "2107303C1A2B",
+			UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Firmware:  &common.Firmware{Installed: "D3MU001"},
+			Status:    &common.Status{State: "Enabled", Health: "OK"},
+			Attributes: &rivets.ComponentAttributes{
+				Slot:          "Disk.Bay.3",
+				CapacityBytes: 480103981056,
+			},
+		},
+		{Name: common.SlugBMC, Vendor: "Dell Inc.", Model: "iDRAC, 9"},
+	}
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+		err         error
+	}{
+		{
+			name:        "json by default",
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name:        "csv",
+			accept:      "text/csv",
+			contentType: "text/csv; charset=utf-8",
+			body: "server,slug,vendor,model,serial,firmware,state,health,slot,part_number,capacity_bytes,size_bytes,updated\n" +
+				"srv,Drive,Micron,MTFDDAK480TDS,2107303C1A2B,D3MU001,Enabled,OK,Disk.Bay.3,,480103981056,,2024-05-01T12:00:00Z\n" +
+				`srv,BMC,Dell Inc.,"iDRAC, 9",,,,,,,,,` + "\n",
+		},
+		{
+			name:        "yaml",
+			accept:      "application/x-yaml",
+			contentType: "application/x-yaml; charset=utf-8",
+			body: "- attributes:\n" +
+				"    capacity_bytes: 480103981056\n" +
+				"    slot: Disk.Bay.3\n" +
+				"  firmware:\n" +
+				"    installed: D3MU001\n" +
+				"  model: MTFDDAK480TDS\n" +
+				"  name: Drive\n" +
+				"  serial: 2107303C1A2B\n" +
+				"  status:\n" +
+				"    Health: OK\n" +
+				"    State: Enabled\n" +
+				"  updated: \"2024-05-01T12:00:00Z\"\n" +
+				"  vendor: Micron\n" +
+				"- model: iDRAC, 9\n" +
+				"  name: BMC\n" +
+				"  updated: \"0001-01-01T00:00:00Z\"\n" +
+				"  vendor: Dell Inc.\n",
+		},
+		{
+			name:        "protobuf preferred",
+			accept:      "application/x-protobuf, application/json",
+			contentType: constants.ProtobufContentType,
+		},
+		{
+			name:   "not acceptable",
+			accept: "application/xml",
+			err:    errNotAcceptable,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.accept != "" {
+				ctx.Request.Header.Set("Accept", tc.accept)
+			}
+
+			format, err := negotiateComponentsFormat(ctx)
+			require.Equal(t, "Accept", rec.Header().Get("Vary"))
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.NoError(t, renderComponents(ctx, format, "srv", components))
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+			if tc.body != "" {
+				require.Equal(t, tc.body, rec.Body.String())
+			}
+
+			if format == constants.ProtobufContentType {
+				msg, err := componentpb.Unmarshal(rec.Body.Bytes())
+				require.NoError(t, err)
+				require.Equal(t, "srv", msg.ServerID)
+				require.Len(t, msg.Components, 2)
+				require.Equal(t, "480103981056", msg.Components[0].Attributes["capacity_bytes"])
+			}
+		})
+	}
+}
+
+func TestComponentsCSVFormulas(t *testing.T) {
+	t.Parallel()
+	body, err := componentsCSV("srv", []*rivets.Component{{
+		Name:       common.SlugDrive,
+		Vendor:     "=HYPERLINK(\"http://example.com\")",
+		Model:      "+1",
+		Serial:     "-2",
+		Attributes: &rivets.ComponentAttributes{Slot: "@SUM(A1)", PartNumber: "\tx"},
+	}})
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, []string{
+		"srv", common.SlugDrive, `'=HYPERLINK("http://example.com")`, "'+1", "'-2", "", "", "",
+		"'@SUM(A1)", "'\tx", "", "", "",
+	}, rows[1])
+}
@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/internal/app"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	fleetdb "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"go.uber.org/zap"
+)
+
+var (
+	// the number of servers evaluated concurrently for a report
+	reportConcurrency = 8
+	// page size used when listing the servers of a facility
+	reportPageSize = 100
+	// how long a report may take to scan its servers, well past the write
+	// timeout of the other routes
+	reportTimeout = 4 * time.Minute
+)
+
+// composeDegradedReportHandler scans the servers in a facility, or an explicit
+// list of servers, and lists every component that is not in an OK state. The
+// scan is limited to reportTimeout, the servers it didn't get to are listed in
+// the errors of the report.
+func composeDegradedReportHandler(theApp *app.App) gin.HandlerFunc {
+	fdb := theApp.FleetDB
+	return func(ctx *gin.Context) {
+		logger := requestLogger(ctx, theApp.Log)
+
+		// a facility-wide scan outlasts the write timeout of the server
+		deadline := time.Now().Add(reportTimeout)
+		if err := http.NewResponseController(ctx.Writer).SetWriteDeadline(deadline.Add(writeTimeout)); err != nil {
+			logger.With(zap.Error(err)).Warn("extending the report write deadline")
+		}
+
+		// gin.Context is not safe to share between goroutines
+		reqCtx, cancel := context.WithDeadline(ctx.Request.Context(), deadline)
+		defer cancel()
+
+		facility := ctx.Query("facility")
+		serverParams := ctx.QueryArray("server")
+
+		if facility == "" && len(serverParams) == 0 {
+			reject(ctx, http.StatusBadRequest, "a facility or server list is required", "")
+			return
+		}
+
+		serverIDs := make([]uuid.UUID, 0, len(serverParams))
+		for _, s := range serverParams {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
+				return
+			}
+			serverIDs = append(serverIDs, id)
+		}
+
+		if facility != "" {
+			ids, err := facilityServerIDs(reqCtx, fdb, facility)
+			if err != nil {
+				logger.With(
+					zap.Error(err),
+					zap.String("facility", facility),
+				).Warn("listing facility servers")
+				reject(ctx, http.StatusInternalServerError, "unable to list facility servers", err.Error())
+				return
+			}
+			serverIDs = append(serverIDs, ids...)
+		}
+
+		inband := inbandFromQuery(ctx)
+		report := &health.Report{
+			Facility: facility,
+			Mode:     modeString(inband),
+			Degraded: []*health.DegradedComponent{},
+		}
+
+		var mtx sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, reportConcurrency)
+
+		for _, id := range dedupe(serverIDs) {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(id uuid.UUID) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				srv, err := getCachedServerInventory(reqCtx, theApp.InventoryCache, fdb, id, inband)
+
+				mtx.Lock()
+				defer mtx.Unlock()
+				if err != nil {
+					report.AddError(id.String(), err)
+					return
+				}
+				report.Add(health.Summarize(id.String(), srv.Components))
+			}(id)
+		}
+		wg.Wait()
+
+		report.Sort()
+		ctx.JSON(http.StatusOK, report)
+	}
+}
+
+// facilityServerIDs pages through FleetDB to collect the ids of all servers in a facility.
+func facilityServerIDs(ctx context.Context, fdb *fleetdb.Client, facility string) ([]uuid.UUID, error) {
+	params := &fleetdb.ServerListParams{
+		FacilityCode: facility,
+		PaginationParams: &fleetdb.PaginationParams{
+			Limit: reportPageSize,
+			Page:  1,
+		},
+	}
+
+	ids := []uuid.UUID{}
+	for {
+		servers, resp, err := listServers(ctx, fdb, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range servers {
+			ids = append(ids, servers[idx].UUID)
+		}
+
+		if resp == nil || !resp.HasNextPage() {
+			return ids, nil
+		}
+		params.PaginationParams.Page++
+	}
+}
+
+func dedupe(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/metal-toolbox/component-inventory/pkg/api/health"
+	rivets "github.com/metal-toolbox/rivets/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDegradedReport(t *testing.T) { // not parallel, see newTestHandler
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+
+	healthy := withComponents(fake, "d1")
+	degraded := withComponents(fake, "d2")
+	other := withComponents(fake, "d3")
+	for _, id := range []uuid.UUID{healthy, degraded} {
+		fake.servers[id.String()].props.Facility = "sandbox"
+	}
+	fake.servers[degraded.String()].store(&rivets.Server{
+		Components: []*rivets.Component{{
+			Name:   common.SlugDrive,
+			Serial: "d2",
+			Status: &common.Status{Health: "Critical", State: "Enabled"},
+		}},
+	}, true)
+
+	path := constants.ReportsEndpoint + constants.DegradedReportPath
+	w := serveRequest(h, http.MethodGet, path+"?facility=sandbox")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	report := &health.Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+	require.Equal(t, 2, report.ServersScanned)
+	require.Len(t, report.Degraded, 1)
+	require.Equal(t, degraded.String(), report.Degraded[0].ServerID)
+	require.Equal(t, health.StatusCritical, report.Degraded[0].Status)
+
+	// servers that can't be evaluated are listed, the others still reported
+	missing := uuid.New()
+	w = serveRequest(h, http.MethodGet, path+"?server="+other.String()+"&server="+missing.String())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report = &health.Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+	require.Equal(t, 1, report.ServersScanned)
+	require.Contains(t, report.Errors, missing.String())
+
+	w = serveRequest(h, http.MethodGet, path)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDegradedReportTimeout(t *testing.T) { // not parallel, see newTestHandler
+	timeout := reportTimeout
+	reportTimeout = 0
+	t.Cleanup(func() { reportTimeout = timeout })
+
+	fake := newFakeFleetDB()
+	h := newFleetDBTestHandler(t, fake)
+	serverID := withComponents(fake, "d1")
+
+	// the servers the scan didn't get to are listed as errors
+	w := serveRequest(h, http.MethodGet, constants.ReportsEndpoint+constants.DegradedReportPath+"?server="+serverID.String())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	report := &health.Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+	require.Zero(t, report.ServersScanned)
+	require.Contains(t, report.Errors, serverID.String())
+}
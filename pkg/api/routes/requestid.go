@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"go.uber.org/zap"
+)
+
+const (
+	// gin context keys for request scoped values
+	requestIDKey     = "request.id"
+	requestLoggerKey = "request.logger"
+
+	// caller supplied request ids longer than this are replaced
+	maxRequestIDLength = 128
+)
+
+// composeRequestID accepts the caller's X-Request-ID, or generates one, and
+// makes it available to handlers, their logger and the caller via the response.
+func composeRequestID(l *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(constants.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Set(requestLoggerKey, l.With(zap.String("request.id", id)))
+		c.Header(constants.RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// validRequestID rejects ids that would be awkward in logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the id of the request being handled.
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// requestLogger returns the logger for the request being handled, falling back
+// to the given logger when the request id middleware is not in use.
+func requestLogger(c *gin.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := c.Get(requestLoggerKey); ok {
+		if logger, ok := l.(*zap.Logger); ok {
+			return logger
+		}
+	}
+	return fallback
+}
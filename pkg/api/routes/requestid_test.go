@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		inbound  string
+		accepted bool
+	}{
+		{"accepted", "my-request.1", true},
+		{"longest accepted", strings.Repeat("a", maxRequestIDLength), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"spaces", "my request", false},
+		{"control characters", "my-request\x01", false},
+		{"not ascii", "my-requést", false},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var handled string
+			g := gin.New()
+			g.Use(composeRequestID(zap.NewNop()))
+			g.GET("/", func(ctx *gin.Context) {
+				handled = requestID(ctx)
+				reject(ctx, http.StatusBadRequest, "invalid request", "")
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.inbound != "" {
+				req.Header.Set(constants.RequestIDHeader, tc.inbound)
+			}
+			g.ServeHTTP(w, req)
+
+			id := w.Header().Get(constants.RequestIDHeader)
+			if tc.accepted {
+				require.Equal(t, tc.inbound, id)
+			} else {
+				_, err := uuid.Parse(id)
+				require.NoError(t, err, "a generated id replaces %q", tc.inbound)
+			}
+			require.Equal(t, id, handled)
+
+			// rejections carry the id for callers to quote
+			body := &errorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), body))
+			require.Equal(t, id, body.RequestID)
+		})
+	}
+}
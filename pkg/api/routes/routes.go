@@ -1,24 +1,32 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
-	"github.com/metal-toolbox/alloy/types"
 	"github.com/metal-toolbox/component-inventory/internal/app"
-	iconv "github.com/metal-toolbox/component-inventory/internal/inventoryconverter"
+	"github.com/metal-toolbox/component-inventory/internal/fingerprint"
+	"github.com/metal-toolbox/component-inventory/internal/idempotency"
+	"github.com/metal-toolbox/component-inventory/internal/jsonlimit"
 	"github.com/metal-toolbox/component-inventory/internal/metrics"
 	"github.com/metal-toolbox/component-inventory/internal/version"
 	"github.com/metal-toolbox/component-inventory/pkg/api/constants"
 	"go.hollow.sh/toolbox/ginauth"
 	"go.hollow.sh/toolbox/ginjwt"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 var (
+	errConversion = errors.New("inventory conversion failed")
+
 	readTimeout  = 10 * time.Second
 	writeTimeout = 20 * time.Second
 
@@ -49,6 +57,7 @@ func composeAppLogging(l *zap.Logger, skippedPaths ...string) gin.HandlerFunc {
 		}
 
 		fields := []zap.Field{
+			zap.String("request.id", requestID(c)),
 			zap.String("path", path),
 			zap.String("query", query),
 			zap.Int("status-code", code),
@@ -86,74 +95,197 @@ func ComposeHTTPServer(theApp *app.App) *http.Server {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// set up common middleware for logging and metrics
-	g.Use(composeAppLogging(theApp.Log, constants.LivenessEndpoint), gin.Recovery())
+	// extract any incoming trace context and open a span for each request
+	g.Use(otelgin.Middleware(app.AppName))
+
+	// set up common middleware for request correlation, logging and metrics
+	g.Use(composeRequestID(theApp.Log))
+	g.Use(composeAppLogging(theApp.Log, constants.LivenessEndpoint, constants.ReadinessEndpoint, metrics.Endpoint), gin.Recovery())
+	g.Use(composeCompression(theApp.Cfg.CompressionOpts.MinResponseBytes))
 
 	// some boilerplate setup
 	g.NoRoute(func(c *gin.Context) {
 		c.JSON(http.StatusNotFound,
 			gin.H{
-				"message": "invalid request - route not found",
+				"message":    "invalid request - route not found",
+				"request_id": requestID(c),
 			},
 		)
 	})
 
+	doc, err := openAPIDocument()
+	if err != nil {
+		theApp.Log.Fatal(
+			"failed to generate the OpenAPI document",
+			zap.Error(err),
+		)
+	}
+
+	r := &router{g: g}
+
 	// a liveness endpoint
-	g.GET(constants.LivenessEndpoint, func(c *gin.Context) {
+	r.handle(http.MethodGet, constants.LivenessEndpoint, nil, func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"time": time.Now()})
 	})
 
-	g.GET(constants.VersionEndpoint, func(c *gin.Context) {
+	// a readiness endpoint that reports on our dependencies
+	r.handle(http.MethodGet, constants.ReadinessEndpoint, nil, func(c *gin.Context) {
+		if theApp.Readiness == nil {
+			c.JSON(http.StatusOK, gin.H{"time": time.Now()})
+			return
+		}
+
+		ready, statuses := theApp.Readiness.Check(c.Request.Context())
+		code := http.StatusOK
+		if !ready {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"time": time.Now(), "dependencies": statuses})
+	})
+
+	r.handle(http.MethodGet, constants.VersionEndpoint, nil, func(c *gin.Context) {
 		c.JSON(http.StatusOK, version.Current())
 	})
 
-	g.POST("/api/echo",
-		composeAuthHandler(createScopes("response")), // auth handler
-		wrapAPICall(apiEcho))                         // api function, wrapped into middleware
+	if theApp.Cfg.MetricsOpts.ServeOnAPI {
+		r.handle(http.MethodGet, metrics.Endpoint, nil, gin.WrapH(metrics.Handler()))
+	}
 
-	g.POST("/api/error",
-		composeAuthHandler(createScopes("response")),
-		wrapAPICall(apiError))
+	// request bodies are read in full, they have to be bounded
+	bodyLimits := theApp.Cfg.BodyLimitOpts
+	jsonLimits := jsonlimit.Limits{MaxDepth: bodyLimits.MaxJSONDepth, MaxElements: bodyLimits.MaxJSONElements}
+	defaultBodyLimits := composeBodyLimits(bodyLimits.Default, jsonLimits)
+
+	// diagnostics for trying out a development deployment
+	if theApp.Cfg.DeveloperMode {
+		r.handle(http.MethodPost, constants.DiagnosticsEndpoint+"/echo",
+			createScopes("response"),
+			defaultBodyLimits,
+			wrapAPICall(apiEcho)) // api function, wrapped into middleware
+
+		r.handle(http.MethodPost, constants.DiagnosticsEndpoint+"/error",
+			createScopes("response"),
+			defaultBodyLimits,
+			wrapAPICall(apiError))
+	}
 
 	// add other API endpoints to the gin Engine as required
 
+	readLimit := composeRateLimit(theApp.Cfg.RateLimitOpts, readRateLimit)
+	writeLimit := composeRateLimit(theApp.Cfg.RateLimitOpts, writeRateLimit)
+
 	// get the components associated with a server
-	g.GET(constants.ComponentsEndpoint+"/:server",
-		composeAuthHandler(readScopes("server:component")),
+	r.handle(http.MethodGet, constants.ComponentsEndpoint+"/:server",
+		readScopes("server:component"),
+		readLimit,
 		func(ctx *gin.Context) {
 			serverID, err := uuid.Parse(ctx.Param("server"))
 			if err != nil {
-				ctx.JSON(http.StatusBadRequest, map[string]any{
-					"message": "invalid server id",
-					"err":     err.Error(),
-				})
+				reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
 				return
 			}
 
-			getInband := true
-			qVal, set := ctx.GetQuery("mode")
-			if set && qVal == constants.OutOfBandMode {
-				getInband = false
+			format, err := negotiateComponentsFormat(ctx)
+			if err != nil {
+				reject(ctx, http.StatusNotAcceptable, "unsupported response format", err.Error())
+				return
 			}
 
-			existing, _, err := theApp.FleetDB.GetServerInventory(ctx, serverID, getInband)
+			existing, err := getCachedServerInventory(ctx.Request.Context(), theApp.InventoryCache, theApp.FleetDB, serverID, inbandFromQuery(ctx))
 			if err != nil {
-				ctx.JSON(http.StatusInternalServerError, map[string]any{
-					"message": "components unavailable",
-					"error":   err.Error(),
-				})
+				requestLogger(ctx, theApp.Log).With(
+					zap.Error(err),
+					zap.String("server.id", serverID.String()),
+				).Warn("server lookup")
+				reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "components unavailable", err.Error())
+				return
+			}
+
+			setComponentsETag(ctx, existing.Components, format)
+			setLastModified(ctx, existing.Components)
+
+			// pollers that have the current components don't need them again
+			if ifNoneMatch(ctx, componentsETag(existing.Components, format)) {
+				ctx.Status(http.StatusNotModified)
 				return
 			}
-			// XXX: parse out the components and present them nicely
-			ctx.JSON(http.StatusOK, existing.Components)
+
+			if err := renderComponents(ctx, format, serverID.String(), existing.Components); err != nil {
+				reject(ctx, http.StatusInternalServerError, "unable to render components", err.Error())
+			}
 		})
 
-	// add an API to ingest inventory data
-	g.POST(constants.InventoryEndpoint+"/:server",
-		composeAuthHandler(updateScopes("server:component")),
+	// get a health summary of the components associated with a server
+	r.handle(http.MethodGet, constants.ComponentsEndpoint+"/:server"+constants.ComponentHealthPath,
+		readScopes("server:component"),
+		readLimit,
+		composeHealthHandler(theApp),
+	)
+
+	// report on degraded components across a facility or a list of servers
+	r.handle(http.MethodGet, constants.ReportsEndpoint+constants.DegradedReportPath,
+		readScopes("server:component"),
+		readLimit,
+		composeDegradedReportHandler(theApp),
+	)
+
+	// add an API to ingest inventory data. Collectors retry submissions, an
+	// Idempotency-Key lets them do so without the inventory being processed twice.
+	r.handle(http.MethodPost, constants.InventoryEndpoint+"/:server",
+		updateScopes("server:component"),
+		writeLimit,
+		composeBodyLimits(bodyLimits.Inventory, jsonLimits),
+		composeBodyValidation(doc, inventorySchema),
+		composeIdempotency(idempotency.NewStore(theApp.Cfg.IdempotencyWindow, theApp.Cfg.IdempotencyMaxEntries), theApp.Log),
 		composeInventoryHandler(theApp),
 	)
 
+	// remove all the components of a server, e.g. when it is decommissioned
+	r.handle(http.MethodDelete, constants.ComponentsEndpoint+"/:server",
+		deleteScopes("server:component"),
+		writeLimit,
+		composeDeleteComponentsHandler(theApp),
+	)
+
+	// remove a single component of a server, e.g. when it was pulled
+	r.handle(http.MethodDelete, constants.ComponentsEndpoint+"/:server/:slug/:serial",
+		deleteScopes("server:component"),
+		writeLimit,
+		composeDeleteComponentHandler(theApp),
+	)
+
+	// operator-maintained components and annotations, kept across submissions
+	r.handle(http.MethodGet, constants.ComponentsEndpoint+"/:server"+constants.OverridesPath,
+		readScopes("server:component"),
+		readLimit,
+		composeGetOverridesHandler(theApp),
+	)
+
+	r.handle(http.MethodPut, constants.ComponentsEndpoint+"/:server"+constants.OverridesPath,
+		updateScopes("server:component"),
+		writeLimit,
+		composeBodyLimits(bodyLimits.Overrides, jsonLimits),
+		composeBodyValidation(doc, overridesSchema),
+		composeSetOverridesHandler(theApp),
+	)
+
+	// list the changes made through the API
+	r.handle(http.MethodGet, constants.ComponentsEndpoint+"/:server"+constants.HistoryPath,
+		readScopes("server:component"),
+		readLimit,
+		composeHistoryHandler(theApp),
+	)
+
+	// list the routes above along with the scopes they require
+	r.handle(http.MethodGet, constants.RoutesEndpoint,
+		readScopes("routes"),
+		readLimit,
+		composeRoutesHandler(r),
+	)
+
+	// describe the API for clients and tooling
+	r.handle(http.MethodGet, constants.OpenAPIEndpoint, nil, composeOpenAPIHandler(doc))
+
 	return &http.Server{
 		Addr:         theApp.Cfg.ListenAddress,
 		Handler:      g,
@@ -174,7 +306,8 @@ func wrapAPICall(fn apiHandler) gin.HandlerFunc {
 		m := make(map[string]any)
 		if err := ctx.BindJSON(&m); err != nil {
 			ctx.JSON(http.StatusBadRequest, map[string]any{
-				"error": err.Error(),
+				"error":      err.Error(),
+				"request_id": requestID(ctx),
 			})
 			return
 		}
@@ -185,84 +318,182 @@ func wrapAPICall(fn apiHandler) gin.HandlerFunc {
 		} else {
 			responseCode = http.StatusInternalServerError
 			obj = map[string]any{
-				"error": err.Error(),
+				"error":      err.Error(),
+				"request_id": requestID(ctx),
 			}
 		}
 		ctx.JSON(responseCode, obj)
 	}
 }
 
+func modeString(inband bool) string {
+	if inband {
+		return constants.InBandMode
+	}
+	return constants.OutOfBandMode
+}
+
+// inbandFromQuery returns false only when the caller explicitly asks for
+// out-of-band data.
+func inbandFromQuery(ctx *gin.Context) bool {
+	qVal, set := ctx.GetQuery("mode")
+	return !set || qVal != constants.OutOfBandMode
+}
+
 func reject(ctx *gin.Context, code int, msg, err string) {
-	ctx.JSON(code, map[string]any{
-		"message": msg,
-		"err":     err,
+	ctx.JSON(code, &errorResponse{
+		Message:   msg,
+		Err:       err,
+		RequestID: requestID(ctx),
 	})
 }
 
 func composeInventoryHandler(theApp *app.App) gin.HandlerFunc {
-	logger := theApp.Log
 	fdb := theApp.FleetDB
 	return func(ctx *gin.Context) {
+		logger := requestLogger(ctx, theApp.Log)
+		inband := inbandFromQuery(ctx)
+		mode := modeString(inband)
+
 		serverID, err := uuid.Parse(ctx.Param("server"))
 		if err != nil {
 			logger.With(
 				zap.Error(err),
 				zap.String("server_param", ctx.Param("server")),
 			).Warn("bad server id")
+			metrics.Ingestion(mode, metrics.IngestionRejected)
 			reject(ctx, http.StatusBadRequest, "invalid server id", err.Error())
 			return
 		}
 
-		inband := true
-		qVal, set := ctx.GetQuery("mode")
-		if set && qVal == constants.OutOfBandMode {
-			inband = false
-		}
-
 		logger.With(
 			zap.String("server.id", serverID.String()),
 			zap.Bool("inband", inband),
 		).Debug("processing inventory")
 
-		var dev types.InventoryDevice
-		if err = ctx.BindJSON(&dev); err != nil {
+		sub, err := decodeInventory(ctx)
+		if err != nil {
 			logger.With(
 				zap.Error(err),
 			).Warn("bad server payload")
-			reject(ctx, http.StatusBadRequest, "invalid server inventory", err.Error())
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			rejectSubmission(ctx, err)
 			return
 		}
 
-		if dev.Inv == nil {
-			logger.Warn("empty inventory")
-			reject(ctx, http.StatusBadRequest, "empty inventory", "")
+		reqCtx := ctx.Request.Context()
+		trace.SpanFromContext(reqCtx).SetAttributes(serverAttributes(serverID, inband)...)
+
+		// inband and out-of-band collectors, or retries, may submit for the
+		// same server at once; only one of them may read-compare-write at a time
+		unlock, err := lockServer(reqCtx, theApp.ServerLock, serverID)
+		if err != nil {
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			rejectLockFailure(ctx, logger, err)
 			return
 		}
+		defer unlock()
 
-		existing, _, err := fdb.GetServerInventory(ctx, serverID, inband)
+		existing, err := getServerInventory(reqCtx, fdb, serverID, inband)
 		if err != nil {
 			logger.With(zap.Error(err)).Warn("server lookup")
-			reject(ctx, http.StatusBadRequest, "unable to retrieve server", err.Error())
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			reject(ctx, fleetDBErrorStatus(err, http.StatusInternalServerError), "unable to retrieve server", err.Error())
+			return
+		}
+
+		// the caller may only update the components it last saw; the tags
+		// sent back are those of the components served as JSON
+		if !ifMatch(ctx, existing.Components) {
+			logger.Info("inventory precondition failed")
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			setComponentsETag(ctx, existing.Components, binding.MIMEJSON)
+			reject(ctx, http.StatusPreconditionFailed, "components were modified", "")
+			return
+		}
+
+		latest, err := convertInventory(reqCtx, existing.Name, existing.Facility, sub)
+		if err != nil {
+			logger.With(zap.Error(err)).Warn("inventory conversion")
+			metrics.ConversionFailure(sub.format())
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			reject(ctx, http.StatusBadRequest, "unable to convert inventory", err.Error())
+			return
+		}
+
+		// formats describing some types of components leave the others as they are
+		if partial, ok := sub.(partialSubmission); ok {
+			keepUncovered(partial, existing, latest)
+		}
+
+		// keep what earlier collections found and this one couldn't read
+		theApp.MergePolicies.Apply(existing, latest)
+
+		// operator-maintained components win over collected ones
+		set, _, err := getOverrides(reqCtx, fdb, serverID)
+		if err != nil {
+			logger.With(zap.Error(err)).Warn("overrides lookup")
+			metrics.Ingestion(mode, metrics.IngestionRejected)
+			reject(ctx, http.StatusInternalServerError, "unable to retrieve overrides", err.Error())
 			return
 		}
+		set.Apply(latest)
 
-		latest := iconv.ToRivetsServer(existing.Name, existing.Facility, dev.Inv, dev.BiosCfg)
 		// sanity check the latest to what exists in FleetDB
-		compareComponents(existing, latest, logger)
+		_, span := tracer.Start(reqCtx, "inventory.compare",
+			trace.WithAttributes(attribute.Int("component.count", len(latest.Components))),
+		)
+		changes := compareComponents(existing, latest, logger)
+		unchanged := fingerprint.Server(existing) == fingerprint.Server(latest)
+		span.SetAttributes(attribute.Bool("inventory.unchanged", unchanged))
+		span.End()
 
-		_, err = fdb.SetServerInventory(ctx, serverID, latest, inband)
+		// most submissions repeat what FleetDB already has, don't rewrite it
+		if unchanged {
+			logger.With(
+				zap.String("server.id", serverID.String()),
+			).Debug("inventory unchanged")
+			metrics.Ingestion(mode, metrics.IngestionUnchanged)
+			setComponentsETag(ctx, existing.Components, binding.MIMEJSON)
+			ctx.JSON(http.StatusOK, map[string]any{
+				"message": "unchanged",
+			})
+			return
+		}
+
+		err = setServerInventory(reqCtx, fdb, serverID, latest, inband)
+		// a failed write may still have been partially applied, to the
+		// component records both modes share
+		theApp.InventoryCache.Invalidate(serverID, true)
+		theApp.InventoryCache.Invalidate(serverID, false)
 		if err != nil {
 			logger.With(
 				zap.Error(err),
 			).Warn("server update to fleet db")
+			metrics.Ingestion(mode, metrics.IngestionRejected)
 			reject(ctx, http.StatusInternalServerError, "unable to process inventory", err.Error())
 			return
 		}
 
+		recordComponentChanges(mode, changes)
+		metrics.Ingestion(mode, metrics.IngestionProcessed)
+		setComponentsETag(ctx, latest.Components, binding.MIMEJSON)
 		ctx.Status(http.StatusCreated)
 	}
 }
 
+func recordComponentChanges(mode string, changes *componentChanges) {
+	for slug, count := range changes.Added {
+		metrics.ComponentChanges(mode, slug, metrics.ComponentAdded, count)
+	}
+	for slug, count := range changes.Removed {
+		metrics.ComponentChanges(mode, slug, metrics.ComponentRemoved, count)
+	}
+	for slug, count := range changes.Changed {
+		metrics.ComponentChanges(mode, slug, metrics.ComponentChanged, count)
+	}
+}
+
 func composeAuthHandler(scopes []string) gin.HandlerFunc {
 	if authMiddleWare == nil {
 		return ginNoOp
@@ -279,7 +510,6 @@ func createScopes(items ...string) []string {
 	return s
 }
 
-//nolint:unused
 func readScopes(items ...string) []string {
 	s := []string{"read"}
 	for _, i := range items {
@@ -289,7 +519,6 @@ func readScopes(items ...string) []string {
 	return s
 }
 
-//nolint:unused
 func updateScopes(items ...string) []string {
 	s := []string{"write", "update"}
 	for _, i := range items {
@@ -299,7 +528,6 @@ func updateScopes(items ...string) []string {
 	return s
 }
 
-//nolint:unused
 func deleteScopes(items ...string) []string {
 	s := []string{"write", "delete"}
 	for _, i := range items {
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// the name of the otel instrumentation for the API service
+const instrumentationName = "github.com/metal-toolbox/component-inventory/pkg/api/routes"
+
+var tracer = otel.Tracer(instrumentationName)
+
+// serverAttributes are the span attributes identifying a server's inventory.
+func serverAttributes(serverID uuid.UUID, inband bool) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("server.id", serverID.String()),
+		attribute.String("inventory.mode", modeString(inband)),
+	}
+}